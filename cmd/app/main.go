@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"sync/atomic"
 	"syscall"
 	"time"
 
@@ -16,6 +17,7 @@ import (
 	"github.com/MikebangSfilya/wb/internal/lib/metrics"
 	"github.com/MikebangSfilya/wb/internal/lib/tracing"
 	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/repository/cache"
 	"github.com/MikebangSfilya/wb/internal/repository/postgresql"
 	redis2 "github.com/MikebangSfilya/wb/internal/repository/redis"
 	"github.com/MikebangSfilya/wb/internal/service"
@@ -24,6 +26,7 @@ import (
 	"github.com/MikebangSfilya/wb/internal/transport/kafka"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/riandyrn/otelchi"
 	"golang.org/x/sync/errgroup"
@@ -35,14 +38,19 @@ func main() {
 		log.Fatal(err)
 	}
 
-	validator.Init()
-
 	sl := sl2.SetupLogger(cfg.Env)
 	slog.SetDefault(sl)
 	sl.Info("config loaded, start application")
 
 	m := metrics.New()
 
+	warnRules, err := validator.WarnRules(cfg.Validation.WarnRules)
+	if err != nil {
+		sl.Error("Invalid validation config", "error", err)
+		os.Exit(1)
+	}
+	validator.Init(append([]validator.Option{validator.WithMetrics(m)}, warnRules...)...)
+
 	tr, shutdownTracer, err := tracing.InitTracer(context.Background(), "wb-service", cfg.Otel.Address)
 	if err != nil {
 		sl.Error("failed to init tracer", "error", err)
@@ -66,21 +74,116 @@ func main() {
 		os.Exit(1)
 	}
 
+	prometheus.MustRegister(metrics.NewPoolCollector(db.Pools()))
+
 	if err := postgre.RunMigrations(cfg); err != nil {
 		sl.Error("Database migrations failed, use make migrate-up", "error", err)
 	}
 
-	r, err := redis2.New(ctx, cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.DB, tr)
+	redisOpts := []redis2.Option{
+		redis2.WithStateHook(func(degraded bool) {
+			if degraded {
+				m.RedisDegraded.Set(1)
+			} else {
+				m.RedisDegraded.Set(0)
+			}
+		}),
+		redis2.WithKeepOnPurge(handlers.IdempotencyPrefix),
+	}
+	if cfg.Redis.SoftFail {
+		redisOpts = append(redisOpts, redis2.WithSoftFail())
+	}
+	r, err := redis2.New(ctx, cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.DB, tr, redisOpts...)
 	if err != nil {
 		sl.Error("Redis connection failed", "error", err)
 		os.Exit(1)
 	}
-	repo := postgresql.New(db.Pool, tr)
-	svc := service.New(sl, repo, r, m, tr)
+	readMode := postgresql.ReadMode(cfg.Database.ReadMode)
+	if !readMode.Valid() {
+		sl.Error("Invalid database read mode", "mode", readMode)
+		os.Exit(1)
+	}
+	repo := postgresql.New(db.Pool, tr,
+		postgresql.WithReplicas(db.Replicas),
+		postgresql.WithReadYourWrites(cfg.Database.ReadYourWritesWindow),
+		postgresql.WithMaxReplicaLag(cfg.Database.ReplicaMaxLag),
+		postgresql.WithReadMode(readMode),
+	)
+
+	var orderCache service.Cache = r
+	var tiered *cache.Tiered
+	if cfg.Cache.LocalSize > 0 {
+		tiered = cache.NewTiered(cache.NewLRU(cfg.Cache.LocalSize, cfg.Cache.LocalTTL), r, m,
+			cache.WithInvalidator(r))
+		orderCache = tiered
+	}
+	svc := service.New(sl, repo, orderCache, m, tr,
+		service.WithNegativeTTL(cfg.Cache.NegativeTTL),
+		service.WithConflictPolicy(cfg.Orders.ConflictPolicy),
+	)
+
+	dlq, closeDLQ, err := newDeadLetterPublisher(ctx, cfg.Kafka.Brokers, cfg.Kafka.DLQTopic)
+	if err != nil {
+		sl.Error("Kafka dead-letter producer init failed", "error", err)
+		os.Exit(1)
+	}
+	defer closeDLQ(sl)
 
-	consumer := kafka.NewConsumer(sl, cfg.Kafka.Brokers, cfg.Kafka.GroupID, cfg.Kafka.Topic, svc)
+	consumer := kafka.NewConsumer(sl, cfg.Kafka.Brokers, cfg.Kafka.GroupID, cfg.Kafka.Topic, cfg.Kafka.Workers, svc, dlq,
+		kafka.WithBatchSize(cfg.Kafka.BatchSize))
 
-	h := handlers.New(sl, svc)
+	var statusConsumer *kafka.Consumer
+	if cfg.Kafka.StatusTopic != "" {
+		statusDLQ, closeStatusDLQ, err := newDeadLetterPublisher(ctx, cfg.Kafka.Brokers, cfg.Kafka.StatusDLQTopic)
+		if err != nil {
+			sl.Error("Kafka status dead-letter producer init failed", "error", err)
+			os.Exit(1)
+		}
+		defer closeStatusDLQ(sl)
+
+		statusConsumer = kafka.NewStatusConsumer(sl, cfg.Kafka.Brokers, cfg.Kafka.StatusGroupID, cfg.Kafka.StatusTopic,
+			cfg.Kafka.Workers, svc, statusDLQ)
+	}
+
+	var relay *kafka.OutboxRelay
+	if cfg.Kafka.CreatedTopic != "" {
+		createdProducer, err := kafka.NewProducer(ctx, cfg.Kafka.Brokers, cfg.Kafka.CreatedTopic)
+		if err != nil {
+			sl.Error("Kafka order events producer init failed", "error", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := createdProducer.Close(); err != nil {
+				sl.Error("Kafka order events producer close error", "error", err)
+			}
+		}()
+		relay = kafka.NewOutboxRelay(sl, repo, createdProducer, cfg.Outbox.PollInterval, cfg.Outbox.BatchSize, cfg.Outbox.Retention)
+	}
+
+	handlerOpts := []handlers.Option{
+		handlers.WithIdempotency(r, cfg.Ingest.IdempotencyTTL),
+		handlers.WithBatchLimit(cfg.Ingest.BatchLimit),
+	}
+	switch cfg.Ingest.Mode {
+	case "direct":
+	case "kafka":
+		ordersProducer, err := kafka.NewProducer(ctx, cfg.Kafka.Brokers, cfg.Kafka.Topic)
+		if err != nil {
+			sl.Error("Kafka orders producer init failed", "error", err)
+			os.Exit(1)
+		}
+		defer func() {
+			if err := ordersProducer.Close(); err != nil {
+				sl.Error("Kafka orders producer close error", "error", err)
+			}
+		}()
+		handlerOpts = append(handlerOpts, handlers.WithPublisher(ordersProducer))
+	default:
+		sl.Error("Invalid ingest mode", "mode", cfg.Ingest.Mode)
+		os.Exit(1)
+	}
+
+	h := handlers.New(sl, svc, handlerOpts...)
 
 	router := chi.NewRouter()
 	router.Use(otelchi.Middleware("wb-service", otelchi.WithChiRoutes(router)))
@@ -89,8 +192,30 @@ func main() {
 	router.Use(middleware.Logger)
 	router.Use(middleware.Recoverer)
 
+	var ready atomic.Bool
+
 	router.Handle("/metrics", promhttp.Handler())
+	router.Get("/ready", handlers.Ready(&ready))
+	router.Get("/health", handlers.Health(map[string]handlers.Dependency{"redis": r}))
 	router.Get("/order/{id}", h.GetOrder())
+	router.Patch("/order/{id}/status", h.UpdateOrderStatus())
+	router.Get("/order/{id}/status/history", h.GetStatusHistory())
+	router.Get("/orders", h.ListOrders())
+	router.Post("/orders", h.CreateOrder())
+	router.Post("/orders/batch", h.CreateOrders())
+	router.Get("/orders/by-track/{track}", h.GetOrderByTrackNumber())
+	router.Get("/orders/by-transaction/{tx}", h.GetOrderByTransaction())
+	router.Get("/orders/by-rid/{rid}", h.GetOrderByRid())
+	if len(cfg.Admin.Tokens) > 0 {
+		router.Route("/admin", func(admin chi.Router) {
+			admin.Use(handlers.AdminAuth(cfg.Admin.Tokens))
+			admin.Get("/orders/{id}/ingestion", h.GetIngestions())
+			admin.Post("/orders/{id}/erasure", h.EraseOrder())
+			admin.Get("/orders/{id}/erasures", h.GetErasures())
+		})
+	} else {
+		sl.Warn("ADMIN_TOKENS is not set, admin API is disabled")
+	}
 	router.Get("/", func(w http.ResponseWriter, r *http.Request) {
 		http.ServeFile(w, r, "./web/static/index.html")
 	})
@@ -111,6 +236,70 @@ func main() {
 		return consumer.Start(ctx)
 	})
 
+	if statusConsumer != nil {
+		g.Go(func() error {
+			return statusConsumer.Start(ctx)
+		})
+	}
+
+	if relay != nil {
+		g.Go(func() error {
+			return relay.Start(ctx)
+		})
+	}
+
+	g.Go(func() error {
+		return r.StartHealthChecks(ctx, cfg.Redis.HealthCheckInterval,
+			cfg.Redis.ReconnectMinBackoff, cfg.Redis.ReconnectMaxBackoff)
+	})
+
+	if tiered != nil {
+		g.Go(func() error {
+			return tiered.Listen(ctx)
+		})
+	}
+
+	if len(db.Replicas) > 0 {
+		g.Go(func() error {
+			return repo.StartReplicaChecks(ctx, cfg.Database.ReplicaCheckInterval)
+		})
+	}
+
+	if cfg.Partitions.Enabled {
+		g.Go(func() error {
+			return svc.RunPartitionMaintenance(ctx, cfg.Partitions.PremakeMonths, cfg.Partitions.RetentionMonths,
+				cfg.Partitions.Interval)
+		})
+	}
+
+	if cfg.Ledger.Retention > 0 {
+		g.Go(func() error {
+			return svc.RunLedgerCleanup(ctx, cfg.Ledger.Retention, cfg.Ledger.Interval, cfg.Ledger.BatchSize)
+		})
+	}
+
+	if cfg.Archive.Enabled {
+		g.Go(func() error {
+			retention := time.Duration(cfg.Archive.RetentionDays) * 24 * time.Hour
+			return svc.RunArchiver(ctx, retention, cfg.Archive.Interval, cfg.Archive.BatchSize)
+		})
+	}
+
+	g.Go(func() error {
+		defer ready.Store(true)
+		if !cfg.Warmup.Enabled {
+			return nil
+		}
+
+		warmupCtx, cancel := context.WithTimeout(ctx, cfg.Warmup.Timeout)
+		defer cancel()
+
+		if err := svc.WarmUp(warmupCtx, cfg.Warmup.Limit, cfg.Warmup.MaxAge, cfg.Warmup.Concurrency); err != nil {
+			sl.Warn("cache warm-up incomplete", "error", err)
+		}
+		return nil
+	})
+
 	g.Go(func() error {
 		<-ctx.Done()
 		sl.Info("shutting down gracefully...")
@@ -127,6 +316,11 @@ func main() {
 		if err := consumer.Close(); err != nil {
 			sl.Error("Kafka consumer close error", "error", err)
 		}
+		if statusConsumer != nil {
+			if err := statusConsumer.Close(); err != nil {
+				sl.Error("Kafka status consumer close error", "error", err)
+			}
+		}
 
 		if err := r.Close(); err != nil {
 			sl.Error("Server forced to close", "error", err)
@@ -149,3 +343,19 @@ func newServer(cfg *config.Config, router chi.Router) *http.Server {
 		IdleTimeout:  cfg.HTTPServer.IdleTimeout,
 	}
 }
+
+func newDeadLetterPublisher(ctx context.Context, brokers []string, topic string) (kafka.DeadLetterPublisher, func(*slog.Logger), error) {
+	if topic == "" {
+		return nil, func(*slog.Logger) {}, nil
+	}
+
+	producer, err := kafka.NewProducer(ctx, brokers, topic)
+	if err != nil {
+		return nil, nil, err
+	}
+	return producer, func(l *slog.Logger) {
+		if err := producer.Close(); err != nil {
+			l.Error("Kafka dead-letter producer close error", "topic", topic, "error", err)
+		}
+	}, nil
+}
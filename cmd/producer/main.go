@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log"
+	"slices"
 	"strconv"
 	"time"
 
@@ -83,9 +84,15 @@ func main() {
 		simpleOrder.OrderUID = simpleID
 		simpleOrder.TrackNumber = "TRACK-" + simpleID
 
-		simpleOrder.Payment.Transaction = "trans-" + simpleID
+		simpleOrder.Payment.Transaction = simpleID
 
+		simpleOrder.Payment.DeliveryCost += i
 		simpleOrder.Payment.Amount += i
+
+		simpleOrder.Items = slices.Clone(mainOrder.Items)
+		for j := range simpleOrder.Items {
+			simpleOrder.Items[j].TrackNumber = simpleOrder.TrackNumber
+		}
 		sendOrder(prod, simpleOrder)
 	}
 
@@ -0,0 +1,284 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/config"
+	sl2 "github.com/MikebangSfilya/wb/internal/lib/log"
+	"github.com/MikebangSfilya/wb/internal/lib/metrics"
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/MikebangSfilya/wb/internal/repository/postgresql"
+	redis2 "github.com/MikebangSfilya/wb/internal/repository/redis"
+	"github.com/MikebangSfilya/wb/internal/service"
+	"github.com/MikebangSfilya/wb/internal/storage/postgre"
+	"github.com/MikebangSfilya/wb/internal/transport/kafka"
+	kafkago "github.com/segmentio/kafka-go"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+const (
+	modeService   = "service"
+	modeRepublish = "republish"
+)
+
+type options struct {
+	topic      string
+	partition  int
+	fromOffset int64
+	toOffset   int64
+	since      string
+	until      string
+	reason     string
+	stage      string
+	orderUID   string
+	mode       string
+	dryRun     bool
+}
+
+type report struct {
+	scanned  int
+	matched  int
+	replayed int
+	invalid  int
+	failed   int
+	byStage  map[string]int
+	byReason map[string]int
+}
+
+type replayer interface {
+	Replay(ctx context.Context, dl kafka.DeadLetter, order *model.Order) error
+}
+
+func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var opts options
+	flag.StringVar(&opts.topic, "topic", cfg.Kafka.DLQTopic, "dead-letter topic to read")
+	flag.IntVar(&opts.partition, "partition", -1, "partition to read, -1 for all")
+	flag.Int64Var(&opts.fromOffset, "from-offset", -1, "first offset to read (inclusive)")
+	flag.Int64Var(&opts.toOffset, "to-offset", -1, "last offset to read (inclusive)")
+	flag.StringVar(&opts.since, "since", "", "read messages written after this RFC3339 time")
+	flag.StringVar(&opts.until, "until", "", "stop at messages written after this RFC3339 time")
+	flag.StringVar(&opts.reason, "reason", "", "replay only messages whose failure reason contains this text")
+	flag.StringVar(&opts.stage, "stage", "", "replay only messages failed at this stage (decode, validate, persist)")
+	flag.StringVar(&opts.orderUID, "order", "", "replay only this order_uid")
+	flag.StringVar(&opts.mode, "mode", modeRepublish, "replay mode: republish to the orders topic or write through the service")
+	flag.BoolVar(&opts.dryRun, "dry-run", false, "print what would be replayed without doing it")
+	flag.Parse()
+
+	rng, err := opts.readRange()
+	if err != nil {
+		log.Fatal(err)
+	}
+	if opts.topic == "" {
+		log.Fatal("dead-letter topic is not set, use -topic or KAFKA_DLQ_TOPIC")
+	}
+
+	warnRules, err := validator.WarnRules(cfg.Validation.WarnRules)
+	if err != nil {
+		log.Fatal(err)
+	}
+	validator.Init(warnRules...)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	var target replayer
+	if !opts.dryRun {
+		var cleanup func()
+		target, cleanup, err = newTarget(ctx, cfg, opts.mode)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer cleanup()
+	}
+
+	rep := report{byStage: map[string]int{}, byReason: map[string]int{}}
+	err = kafka.ReadTopic(ctx, cfg.Kafka.Brokers, opts.topic, rng, func(m kafkago.Message) error {
+		rep.scanned++
+		dl := kafka.ParseDeadLetter(m)
+		if !opts.matches(dl) {
+			return nil
+		}
+
+		var order model.Order
+		if err := json.Unmarshal(m.Value, &order); err != nil {
+			rep.invalid++
+			log.Printf("skip offset %d: invalid json: %v", m.Offset, err)
+			return nil
+		}
+		if opts.orderUID != "" && order.OrderUID != opts.orderUID {
+			return nil
+		}
+
+		rep.matched++
+		rep.byStage[dl.Stage]++
+		rep.byReason[dl.Reason]++
+
+		warnings, err := validator.Validate(&order)
+		if err != nil {
+			rep.invalid++
+			log.Printf("skip offset %d order %s: still invalid: %v", m.Offset, order.OrderUID, err)
+			return nil
+		}
+		for _, w := range warnings {
+			log.Printf("offset %d order %s: warning: %s: %s (%s)", m.Offset, order.OrderUID, w.Field, w.Message, w.Rule)
+		}
+
+		if opts.dryRun {
+			fmt.Printf("would replay partition=%d offset=%d order=%s stage=%s attempts=%d failed_at=%s reason=%q\n",
+				m.Partition, m.Offset, order.OrderUID, dl.Stage, dl.Attempts,
+				dl.FailedAt.Format(time.RFC3339), dl.Reason)
+			rep.replayed++
+			return nil
+		}
+
+		if err := target.Replay(ctx, dl, &order); err != nil {
+			rep.failed++
+			log.Printf("failed to replay offset %d order %s: %v", m.Offset, order.OrderUID, err)
+			return nil
+		}
+		rep.replayed++
+		log.Printf("replayed offset %d order %s", m.Offset, order.OrderUID)
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("replay stopped: %v", err)
+	}
+
+	rep.print(opts.dryRun)
+}
+
+func (o options) readRange() (kafka.Range, error) {
+	rng := kafka.Range{
+		Partition:  o.partition,
+		FromOffset: o.fromOffset,
+		ToOffset:   o.toOffset,
+	}
+
+	var err error
+	if o.since != "" {
+		if rng.Since, err = time.Parse(time.RFC3339, o.since); err != nil {
+			return rng, fmt.Errorf("invalid -since: %w", err)
+		}
+	}
+	if o.until != "" {
+		if rng.Until, err = time.Parse(time.RFC3339, o.until); err != nil {
+			return rng, fmt.Errorf("invalid -until: %w", err)
+		}
+	}
+
+	switch o.mode {
+	case modeService, modeRepublish:
+	default:
+		return rng, fmt.Errorf("unknown -mode %q", o.mode)
+	}
+
+	return rng, nil
+}
+
+func (o options) matches(dl kafka.DeadLetter) bool {
+	if o.stage != "" && dl.Stage != o.stage {
+		return false
+	}
+	if o.reason != "" && !strings.Contains(dl.Reason, o.reason) {
+		return false
+	}
+	if o.orderUID != "" && len(dl.Message.Key) > 0 && string(dl.Message.Key) != o.orderUID {
+		return false
+	}
+	return true
+}
+
+func newTarget(ctx context.Context, cfg *config.Config, mode string) (replayer, func(), error) {
+	if mode == modeRepublish {
+		prod, err := kafka.NewProducer(ctx, cfg.Kafka.Brokers, cfg.Kafka.Topic)
+		if err != nil {
+			return nil, nil, err
+		}
+		return republisher{prod: prod}, func() { _ = prod.Close() }, nil
+	}
+
+	db, err := postgre.New(ctx, cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	tr := noop.NewTracerProvider().Tracer("wb-replay")
+	r, err := redis2.New(ctx, cfg.Redis.Host, cfg.Redis.Port, cfg.Redis.Password, cfg.Redis.DB, tr)
+	if err != nil {
+		db.Close()
+		return nil, nil, err
+	}
+
+	sl := sl2.SetupLogger(cfg.Env)
+	svc := service.New(sl, postgresql.New(db.Pool, tr), r, metrics.New(), tr,
+		service.WithConflictPolicy(cfg.Orders.ConflictPolicy))
+	return serviceReplayer{svc: svc}, func() {
+		_ = r.Close()
+		db.Close()
+	}, nil
+}
+
+type republisher struct {
+	prod *kafka.Producer
+}
+
+func (p republisher) Replay(ctx context.Context, dl kafka.DeadLetter, order *model.Order) error {
+	return p.prod.SendMessage(ctx, order.OrderUID, dl.Message.Value, dl.OriginalHeaders()...)
+}
+
+type serviceReplayer struct {
+	svc *service.OrderService
+}
+
+func (s serviceReplayer) Replay(ctx context.Context, _ kafka.DeadLetter, order *model.Order) error {
+	_, err := s.svc.CreateOrder(ctx, order)
+	return err
+}
+
+func (r report) print(dryRun bool) {
+	verb := "replayed"
+	if dryRun {
+		verb = "would replay"
+	}
+
+	fmt.Println("replay summary:")
+	fmt.Printf("  scanned: %d\n", r.scanned)
+	fmt.Printf("  matched: %d\n", r.matched)
+	fmt.Printf("  %s: %d\n", verb, r.replayed)
+	fmt.Printf("  still invalid: %d\n", r.invalid)
+	fmt.Printf("  failed: %d\n", r.failed)
+
+	printCounts("by stage", r.byStage)
+	printCounts("by reason", r.byReason)
+}
+
+func printCounts(title string, counts map[string]int) {
+	if len(counts) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(counts))
+	for k := range counts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	fmt.Printf("  %s:\n", title)
+	for _, k := range keys {
+		fmt.Printf("    %q: %d\n", k, counts[k])
+	}
+}
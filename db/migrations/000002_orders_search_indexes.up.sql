@@ -0,0 +1,11 @@
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service ON orders (delivery_service, date_created DESC);
+
+CREATE INDEX IF NOT EXISTS idx_payment_order_uid ON payment (order_uid);
+CREATE INDEX IF NOT EXISTS idx_payment_provider_bank ON payment (provider, bank);
+CREATE INDEX IF NOT EXISTS idx_payment_amount ON payment (amount, order_uid);
+
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_brand ON items (brand);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items (nm_id);
@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS idx_items_rid;
+DROP INDEX IF EXISTS idx_orders_track_number;
@@ -0,0 +1,3 @@
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_items_rid ON items (rid);
+-- payment.transaction is the primary key and already has a unique index.
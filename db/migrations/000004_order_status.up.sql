@@ -0,0 +1,16 @@
+ALTER TABLE orders ADD COLUMN IF NOT EXISTS status TEXT NOT NULL DEFAULT 'created'
+    CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned'));
+
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
+    from_status TEXT,
+    to_status TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_status_history_order_uid ON order_status_history (order_uid, id);
+
+INSERT INTO order_status_history (order_uid, from_status, to_status, changed_at)
+SELECT order_uid, NULL, status, date_created FROM orders;
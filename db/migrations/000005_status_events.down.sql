@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS uniq_order_status_history_event_id;
+
+ALTER TABLE order_status_history DROP COLUMN IF EXISTS event_id;
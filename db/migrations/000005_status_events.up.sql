@@ -0,0 +1,4 @@
+ALTER TABLE order_status_history ADD COLUMN IF NOT EXISTS event_id TEXT;
+
+CREATE UNIQUE INDEX IF NOT EXISTS uniq_order_status_history_event_id
+    ON order_status_history (event_id) WHERE event_id IS NOT NULL;
@@ -0,0 +1,3 @@
+ALTER TABLE orders
+    DROP COLUMN IF EXISTS content_hash,
+    DROP COLUMN IF EXISTS version;
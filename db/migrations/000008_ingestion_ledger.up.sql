@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS ingestion_ledger (
+    topic TEXT NOT NULL,
+    partition INTEGER NOT NULL,
+    "offset" BIGINT NOT NULL,
+    order_uid TEXT NOT NULL,
+    payload_hash TEXT NOT NULL,
+    save_status TEXT NOT NULL,
+    conflicting BOOLEAN NOT NULL DEFAULT false,
+    processed_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+    PRIMARY KEY (topic, partition, "offset")
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingestion_ledger_order_uid ON ingestion_ledger (order_uid, processed_at);
@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS order_erasures (
+    id BIGSERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    requested_by TEXT NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    erased_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS idx_order_erasures_order_uid ON order_erasures (order_uid, erased_at);
@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS orders_archive (
+    order_uid TEXT PRIMARY KEY,
+    date_created TIMESTAMPTZ NOT NULL,
+    data JSONB NOT NULL,
+    archived_at TIMESTAMPTZ NOT NULL DEFAULT now()
+);
@@ -0,0 +1,116 @@
+CREATE TABLE orders_plain (
+    order_uid TEXT PRIMARY KEY,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT NOT NULL,
+    internal_signature TEXT,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INTEGER NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'created'
+        CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned')),
+    version INTEGER NOT NULL DEFAULT 1,
+    content_hash TEXT NOT NULL DEFAULT ''
+);
+
+INSERT INTO orders_plain (
+    order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, status, version, content_hash)
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, status, version, content_hash
+FROM orders;
+
+CREATE TABLE delivery_plain (
+    order_uid TEXT PRIMARY KEY REFERENCES orders_plain(order_uid) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL
+);
+
+INSERT INTO delivery_plain (order_uid, name, phone, zip, city, address, region, email)
+SELECT order_uid, name, phone, zip, city, address, region, email FROM delivery;
+
+CREATE TABLE payment_plain (
+    transaction TEXT PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders_plain(order_uid) ON DELETE CASCADE,
+    request_id TEXT,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost INTEGER NOT NULL CHECK (delivery_cost >= 0),
+    goods_total INTEGER NOT NULL CHECK (goods_total >= 0),
+    custom_fee INTEGER NOT NULL CHECK (custom_fee >= 0)
+);
+
+INSERT INTO payment_plain (
+    transaction, order_uid, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee)
+SELECT transaction, order_uid, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee
+FROM payment;
+
+CREATE TABLE items_plain (
+    id SERIAL PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES orders_plain(order_uid) ON DELETE CASCADE,
+    chrt_id INTEGER NOT NULL,
+    track_number TEXT NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INTEGER NOT NULL CHECK (sale >= 0),
+    size TEXT NOT NULL,
+    total_price INTEGER NOT NULL CHECK (total_price >= 0),
+    nm_id INTEGER NOT NULL,
+    brand TEXT NOT NULL,
+    status INTEGER NOT NULL
+);
+
+INSERT INTO items_plain (
+    id, order_uid, chrt_id, track_number, price, rid, name, sale,
+    size, total_price, nm_id, brand, status)
+SELECT id, order_uid, chrt_id, track_number, price, rid, name, sale,
+    size, total_price, nm_id, brand, status
+FROM items;
+
+SELECT setval(pg_get_serial_sequence('items_plain', 'id'), coalesce(max(id), 0) + 1, false)
+FROM items_plain;
+
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS order_status_history_order_uid_fkey;
+
+DROP TABLE items;
+DROP TABLE payment;
+DROP TABLE delivery;
+DROP TABLE orders;
+DROP TABLE order_keys;
+
+ALTER TABLE orders_plain RENAME TO orders;
+ALTER TABLE delivery_plain RENAME TO delivery;
+ALTER TABLE payment_plain RENAME TO payment;
+ALTER TABLE items_plain RENAME TO items;
+ALTER SEQUENCE items_plain_id_seq RENAME TO items_id_seq;
+
+ALTER TABLE order_status_history ADD CONSTRAINT order_status_history_order_uid_fkey
+    FOREIGN KEY (order_uid) REFERENCES orders (order_uid) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service ON orders (delivery_service, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number, date_created DESC);
+
+CREATE INDEX IF NOT EXISTS idx_payment_order_uid ON payment (order_uid);
+CREATE INDEX IF NOT EXISTS idx_payment_provider_bank ON payment (provider, bank);
+CREATE INDEX IF NOT EXISTS idx_payment_amount ON payment (amount, order_uid);
+
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_brand ON items (brand);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items (nm_id);
+CREATE INDEX IF NOT EXISTS idx_items_rid ON items (rid);
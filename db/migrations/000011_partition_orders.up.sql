@@ -0,0 +1,165 @@
+-- order_uid cannot stay globally unique in a table partitioned by date_created,
+-- so uniqueness moves to order_keys and the order tables reference it.
+CREATE TABLE IF NOT EXISTS order_keys (
+    order_uid TEXT PRIMARY KEY
+);
+
+INSERT INTO order_keys (order_uid) SELECT order_uid FROM orders;
+
+ALTER TABLE order_status_history DROP CONSTRAINT IF EXISTS order_status_history_order_uid_fkey;
+ALTER TABLE order_status_history ADD CONSTRAINT order_status_history_order_uid_fkey
+    FOREIGN KEY (order_uid) REFERENCES order_keys (order_uid) ON DELETE CASCADE;
+
+CREATE TABLE orders_partitioned (
+    order_uid TEXT NOT NULL,
+    track_number TEXT NOT NULL,
+    entry TEXT NOT NULL,
+    locale TEXT NOT NULL,
+    internal_signature TEXT,
+    customer_id TEXT NOT NULL,
+    delivery_service TEXT NOT NULL,
+    shardkey TEXT NOT NULL,
+    sm_id INTEGER NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    oof_shard TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'created'
+        CHECK (status IN ('created', 'paid', 'assembling', 'shipped', 'delivered', 'cancelled', 'returned')),
+    version INTEGER NOT NULL DEFAULT 1,
+    content_hash TEXT NOT NULL DEFAULT ''
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE delivery_partitioned (
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    name TEXT NOT NULL,
+    phone TEXT NOT NULL,
+    zip TEXT NOT NULL,
+    city TEXT NOT NULL,
+    address TEXT NOT NULL,
+    region TEXT NOT NULL,
+    email TEXT NOT NULL
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE payment_partitioned (
+    transaction TEXT NOT NULL,
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    request_id TEXT,
+    currency TEXT NOT NULL,
+    provider TEXT NOT NULL,
+    amount INTEGER NOT NULL CHECK (amount >= 0),
+    payment_dt BIGINT NOT NULL,
+    bank TEXT NOT NULL,
+    delivery_cost INTEGER NOT NULL CHECK (delivery_cost >= 0),
+    goods_total INTEGER NOT NULL CHECK (goods_total >= 0),
+    custom_fee INTEGER NOT NULL CHECK (custom_fee >= 0)
+) PARTITION BY RANGE (date_created);
+
+CREATE TABLE items_partitioned (
+    id BIGSERIAL,
+    order_uid TEXT NOT NULL,
+    date_created TIMESTAMPTZ NOT NULL,
+    chrt_id INTEGER NOT NULL,
+    track_number TEXT NOT NULL,
+    price INTEGER NOT NULL CHECK (price >= 0),
+    rid TEXT NOT NULL,
+    name TEXT NOT NULL,
+    sale INTEGER NOT NULL CHECK (sale >= 0),
+    size TEXT NOT NULL,
+    total_price INTEGER NOT NULL CHECK (total_price >= 0),
+    nm_id INTEGER NOT NULL,
+    brand TEXT NOT NULL,
+    status INTEGER NOT NULL
+) PARTITION BY RANGE (date_created);
+
+-- Monthly partitions (UTC) from the oldest order up to three months ahead.
+-- Rows outside of them go to the default partitions.
+DO $$
+DECLARE
+    m DATE := date_trunc('month', coalesce((SELECT min(date_created) FROM orders), now()) AT TIME ZONE 'UTC')::DATE;
+    last DATE := (date_trunc('month', now() AT TIME ZONE 'UTC') + INTERVAL '3 months')::DATE;
+    t TEXT;
+BEGIN
+    WHILE m <= last LOOP
+        FOREACH t IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+            EXECUTE format('CREATE TABLE %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                t || '_p' || to_char(m, 'YYYYMM'), t || '_partitioned',
+                m::TIMESTAMP AT TIME ZONE 'UTC', (m + INTERVAL '1 month')::TIMESTAMP AT TIME ZONE 'UTC');
+        END LOOP;
+        m := (m + INTERVAL '1 month')::DATE;
+    END LOOP;
+END $$;
+
+CREATE TABLE orders_default PARTITION OF orders_partitioned DEFAULT;
+CREATE TABLE delivery_default PARTITION OF delivery_partitioned DEFAULT;
+CREATE TABLE payment_default PARTITION OF payment_partitioned DEFAULT;
+CREATE TABLE items_default PARTITION OF items_partitioned DEFAULT;
+
+INSERT INTO orders_partitioned (
+    order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, status, version, content_hash)
+SELECT order_uid, track_number, entry, locale, internal_signature, customer_id, delivery_service,
+    shardkey, sm_id, date_created, oof_shard, status, version, content_hash
+FROM orders;
+
+INSERT INTO delivery_partitioned (order_uid, date_created, name, phone, zip, city, address, region, email)
+SELECT d.order_uid, o.date_created, d.name, d.phone, d.zip, d.city, d.address, d.region, d.email
+FROM delivery d JOIN orders o ON o.order_uid = d.order_uid;
+
+INSERT INTO payment_partitioned (
+    transaction, order_uid, date_created, request_id, currency, provider, amount,
+    payment_dt, bank, delivery_cost, goods_total, custom_fee)
+SELECT p.transaction, p.order_uid, o.date_created, p.request_id, p.currency, p.provider, p.amount,
+    p.payment_dt, p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+FROM payment p JOIN orders o ON o.order_uid = p.order_uid;
+
+INSERT INTO items_partitioned (
+    id, order_uid, date_created, chrt_id, track_number, price, rid, name, sale,
+    size, total_price, nm_id, brand, status)
+SELECT i.id, i.order_uid, o.date_created, i.chrt_id, i.track_number, i.price, i.rid, i.name, i.sale,
+    i.size, i.total_price, i.nm_id, i.brand, i.status
+FROM items i JOIN orders o ON o.order_uid = i.order_uid;
+
+SELECT setval(pg_get_serial_sequence('items_partitioned', 'id'), coalesce(max(id), 0) + 1, false)
+FROM items_partitioned;
+
+DROP TABLE items;
+DROP TABLE payment;
+DROP TABLE delivery;
+DROP TABLE orders;
+
+ALTER TABLE orders_partitioned RENAME TO orders;
+ALTER TABLE delivery_partitioned RENAME TO delivery;
+ALTER TABLE payment_partitioned RENAME TO payment;
+ALTER TABLE items_partitioned RENAME TO items;
+ALTER SEQUENCE items_partitioned_id_seq RENAME TO items_id_seq;
+
+ALTER TABLE orders ADD PRIMARY KEY (order_uid, date_created);
+ALTER TABLE orders ADD CONSTRAINT orders_order_uid_fkey
+    FOREIGN KEY (order_uid) REFERENCES order_keys (order_uid) ON DELETE CASCADE;
+
+ALTER TABLE delivery ADD PRIMARY KEY (order_uid, date_created);
+ALTER TABLE delivery ADD CONSTRAINT delivery_order_fkey
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+
+ALTER TABLE payment ADD PRIMARY KEY (transaction, date_created);
+ALTER TABLE payment ADD CONSTRAINT payment_order_fkey
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+
+ALTER TABLE items ADD PRIMARY KEY (id, date_created);
+ALTER TABLE items ADD CONSTRAINT items_order_fkey
+    FOREIGN KEY (order_uid, date_created) REFERENCES orders (order_uid, date_created) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_date_created ON orders (date_created DESC, order_uid DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_customer_id ON orders (customer_id, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_delivery_service ON orders (delivery_service, date_created DESC);
+CREATE INDEX IF NOT EXISTS idx_orders_track_number ON orders (track_number, date_created DESC);
+
+CREATE INDEX IF NOT EXISTS idx_payment_order_uid ON payment (order_uid);
+CREATE INDEX IF NOT EXISTS idx_payment_provider_bank ON payment (provider, bank);
+CREATE INDEX IF NOT EXISTS idx_payment_amount ON payment (amount, order_uid);
+
+CREATE INDEX IF NOT EXISTS idx_items_order_uid ON items (order_uid);
+CREATE INDEX IF NOT EXISTS idx_items_brand ON items (brand);
+CREATE INDEX IF NOT EXISTS idx_items_nm_id ON items (nm_id);
+CREATE INDEX IF NOT EXISTS idx_items_rid ON items (rid);
@@ -0,0 +1 @@
+DROP INDEX IF EXISTS idx_ingestion_ledger_processed_at;
@@ -0,0 +1 @@
+CREATE INDEX IF NOT EXISTS idx_ingestion_ledger_processed_at ON ingestion_ledger (processed_at);
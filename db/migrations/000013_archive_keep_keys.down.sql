@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS idx_orders_archive_items;
+DROP INDEX IF EXISTS idx_orders_archive_transaction;
+DROP INDEX IF EXISTS idx_orders_archive_track_number;
+
+ALTER TABLE orders_archive DROP CONSTRAINT IF EXISTS orders_archive_order_uid_fkey;
+ALTER TABLE orders_archive DROP COLUMN IF EXISTS content_hash;
@@ -0,0 +1,11 @@
+-- Archived orders keep their order_keys row, so a re-sent message cannot
+-- insert the order again and its status history is kept.
+INSERT INTO order_keys (order_uid) SELECT order_uid FROM orders_archive ON CONFLICT DO NOTHING;
+
+ALTER TABLE orders_archive ADD COLUMN IF NOT EXISTS content_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE orders_archive ADD CONSTRAINT orders_archive_order_uid_fkey
+    FOREIGN KEY (order_uid) REFERENCES order_keys (order_uid) ON DELETE CASCADE;
+
+CREATE INDEX IF NOT EXISTS idx_orders_archive_track_number ON orders_archive ((data->>'track_number'));
+CREATE INDEX IF NOT EXISTS idx_orders_archive_transaction ON orders_archive ((data->'payment'->>'transaction'));
+CREATE INDEX IF NOT EXISTS idx_orders_archive_items ON orders_archive USING GIN ((data->'items') jsonb_path_ops);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS orders_default PARTITION OF orders DEFAULT;
+CREATE TABLE IF NOT EXISTS delivery_default PARTITION OF delivery DEFAULT;
+CREATE TABLE IF NOT EXISTS payment_default PARTITION OF payment DEFAULT;
+CREATE TABLE IF NOT EXISTS items_default PARTITION OF items DEFAULT;
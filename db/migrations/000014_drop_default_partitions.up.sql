@@ -0,0 +1,44 @@
+-- A default partition with rows in a month blocks creating that month's
+-- partition, so the rows move to monthly partitions and the defaults go away.
+-- The application creates a month's partitions before writing into it.
+CREATE TEMP TABLE moved_orders AS SELECT * FROM orders_default;
+CREATE TEMP TABLE moved_delivery AS SELECT * FROM delivery_default;
+CREATE TEMP TABLE moved_payment AS SELECT * FROM payment_default;
+CREATE TEMP TABLE moved_items AS SELECT * FROM items_default;
+
+-- Details go with the orders through ON DELETE CASCADE.
+DELETE FROM orders_default;
+
+DROP TABLE items_default;
+DROP TABLE payment_default;
+DROP TABLE delivery_default;
+DROP TABLE orders_default;
+
+DO $$
+DECLARE
+    m DATE;
+    t TEXT;
+BEGIN
+    FOR m IN
+        SELECT DISTINCT date_trunc('month', date_created AT TIME ZONE 'UTC')::DATE FROM moved_orders
+        UNION
+        SELECT generate_series(date_trunc('month', now() AT TIME ZONE 'UTC'),
+            date_trunc('month', now() AT TIME ZONE 'UTC') + INTERVAL '3 months', INTERVAL '1 month')::DATE
+    LOOP
+        FOREACH t IN ARRAY ARRAY['orders', 'delivery', 'payment', 'items'] LOOP
+            EXECUTE format('CREATE TABLE IF NOT EXISTS %I PARTITION OF %I FOR VALUES FROM (%L) TO (%L)',
+                t || '_p' || to_char(m, 'YYYYMM'), t,
+                m::TIMESTAMP AT TIME ZONE 'UTC', (m + INTERVAL '1 month')::TIMESTAMP AT TIME ZONE 'UTC');
+        END LOOP;
+    END LOOP;
+END $$;
+
+INSERT INTO orders SELECT * FROM moved_orders;
+INSERT INTO delivery SELECT * FROM moved_delivery;
+INSERT INTO payment SELECT * FROM moved_payment;
+INSERT INTO items SELECT * FROM moved_items;
+
+DROP TABLE moved_items;
+DROP TABLE moved_payment;
+DROP TABLE moved_delivery;
+DROP TABLE moved_orders;
@@ -0,0 +1,21 @@
+-- The payment primary key includes date_created, so it no longer keeps
+-- transactions unique across partitions. transaction_keys does, for live and
+-- archived orders alike.
+CREATE TABLE IF NOT EXISTS transaction_keys (
+    transaction TEXT PRIMARY KEY,
+    order_uid TEXT NOT NULL REFERENCES order_keys (order_uid) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_transaction_keys_order_uid ON transaction_keys (order_uid);
+
+-- The oldest order keeps a transaction that is already duplicated.
+INSERT INTO transaction_keys (transaction, order_uid)
+SELECT transaction, order_uid
+FROM (
+    SELECT transaction, order_uid, date_created FROM payment
+    UNION ALL
+    SELECT data->'payment'->>'transaction', order_uid, date_created FROM orders_archive
+    WHERE data->'payment'->>'transaction' IS NOT NULL
+) t
+ORDER BY date_created
+ON CONFLICT (transaction) DO NOTHING;
@@ -0,0 +1,6 @@
+DELETE FROM order_keys k
+WHERE k.erased_at IS NOT NULL
+    AND NOT EXISTS (SELECT 1 FROM orders o WHERE o.order_uid = k.order_uid)
+    AND NOT EXISTS (SELECT 1 FROM orders_archive a WHERE a.order_uid = k.order_uid);
+
+ALTER TABLE order_keys DROP COLUMN IF EXISTS erased_at;
@@ -0,0 +1,13 @@
+-- An erased order keeps its order_keys row as a tombstone, so a replayed or
+-- re-sent message cannot store the order again.
+ALTER TABLE order_keys ADD COLUMN IF NOT EXISTS erased_at TIMESTAMPTZ;
+
+INSERT INTO order_keys (order_uid, erased_at)
+SELECT order_uid, max(erased_at) FROM order_erasures GROUP BY order_uid
+ON CONFLICT (order_uid) DO UPDATE SET erased_at = EXCLUDED.erased_at;
+
+-- A re-sent original payload must not match an anonymized order.
+UPDATE orders SET content_hash = ''
+WHERE order_uid IN (SELECT order_uid FROM order_erasures);
+UPDATE orders_archive SET content_hash = ''
+WHERE order_uid IN (SELECT order_uid FROM order_erasures);
@@ -5,6 +5,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/MikebangSfilya/wb/internal/model"
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
@@ -13,8 +14,18 @@ type Config struct {
 	HTTPServer HTTPServer
 	Database   DatabaseConfig
 	Redis      RedisConfig
+	Cache      CacheConfig
+	Orders     OrdersConfig
 	Kafka      KafkaConfig
 	Otel       OtelConfig
+	Warmup     WarmupConfig
+	Outbox     OutboxConfig
+	Validation ValidationConfig
+	Ingest     IngestConfig
+	Archive    ArchiveConfig
+	Ledger     LedgerConfig
+	Partitions PartitionConfig
+	Admin      AdminConfig
 }
 
 type RedisConfig struct {
@@ -22,6 +33,23 @@ type RedisConfig struct {
 	Port     string `env:"REDIS_PORT" env-default:"6379"`
 	Password string `env:"REDIS_PASSWORD"`
 	DB       int    `env:"REDIS_DB" env-default:"0"`
+
+	// SoftFail starts the service with a degraded cache when Redis is not
+	// reachable instead of exiting.
+	SoftFail            bool          `env:"REDIS_SOFT_FAIL" env-default:"true"`
+	HealthCheckInterval time.Duration `env:"REDIS_HEALTH_CHECK_INTERVAL" env-default:"5s"`
+	ReconnectMinBackoff time.Duration `env:"REDIS_RECONNECT_MIN_BACKOFF" env-default:"1s"`
+	ReconnectMaxBackoff time.Duration `env:"REDIS_RECONNECT_MAX_BACKOFF" env-default:"30s"`
+}
+
+type CacheConfig struct {
+	LocalSize   int           `env:"CACHE_LOCAL_SIZE" env-default:"10000"`
+	LocalTTL    time.Duration `env:"CACHE_LOCAL_TTL" env-default:"1m"`
+	NegativeTTL time.Duration `env:"CACHE_NEGATIVE_TTL" env-default:"30s"`
+}
+
+type OrdersConfig struct {
+	ConflictPolicy model.ConflictPolicy `env:"ORDER_CONFLICT_POLICY" env-default:"ignore"`
 }
 
 type HTTPServer struct {
@@ -31,17 +59,103 @@ type HTTPServer struct {
 }
 
 type DatabaseConfig struct {
+	// DSN is a postgres:// URL. When it is set, the discrete connection and
+	// TLS options below are ignored.
+	DSN      string `env:"DB_DSN"`
 	Host     string `env:"DB_HOST" env-default:"localhost"`
 	Port     string `env:"DB_PORT" env-default:"5432"`
 	User     string `env:"DB_USER" env-default:"postgres"`
 	Password string `env:"DB_PASSWORD" env-default:"secret"`
 	Name     string `env:"DB_NAME" env-default:"postgres"`
+
+	SSLMode     string `env:"DB_SSLMODE" env-default:"disable"`
+	SSLRootCert string `env:"DB_SSLROOTCERT"`
+	SSLCert     string `env:"DB_SSLCERT"`
+	SSLKey      string `env:"DB_SSLKEY"`
+
+	MaxConns          int32         `env:"DB_MAX_CONNS" env-default:"10"`
+	MinConns          int32         `env:"DB_MIN_CONNS" env-default:"2"`
+	MaxConnLifetime   time.Duration `env:"DB_MAX_CONN_LIFETIME" env-default:"1h"`
+	MaxConnIdleTime   time.Duration `env:"DB_MAX_CONN_IDLE_TIME" env-default:"30m"`
+	HealthCheckPeriod time.Duration `env:"DB_HEALTH_CHECK_PERIOD" env-default:"1m"`
+	StatementTimeout  time.Duration `env:"DB_STATEMENT_TIMEOUT" env-default:"0s"`
+	ApplicationName   string        `env:"DB_APPLICATION_NAME" env-default:"wb-service"`
+
+	// ReadMode is how a single order is loaded: "joins" or "json".
+	ReadMode string `env:"DB_READ_MODE" env-default:"joins"`
+
+	// Replicas are host:port addresses of read replicas with the same
+	// credentials and database name as the primary.
+	Replicas             []string      `env:"DB_REPLICAS" env-separator:","`
+	ReplicaCheckInterval time.Duration `env:"DB_REPLICA_CHECK_INTERVAL" env-default:"5s"`
+	ReadYourWritesWindow time.Duration `env:"DB_READ_YOUR_WRITES_WINDOW" env-default:"5s"`
+	ReplicaMaxLag        time.Duration `env:"DB_REPLICA_MAX_LAG" env-default:"1s"`
 }
 
 type KafkaConfig struct {
-	Brokers []string `env:"KAFKA_BROKERS" env-default:"localhost:9092"`
-	Topic   string   `env:"KAFKA_TOPIC" env-default:"orders"`
-	GroupID string   `env:"KAFKA_GROUP_ID" env-default:"wb-group"`
+	Brokers        []string `env:"KAFKA_BROKERS" env-default:"localhost:9092"`
+	Topic          string   `env:"KAFKA_TOPIC" env-default:"orders"`
+	GroupID        string   `env:"KAFKA_GROUP_ID" env-default:"wb-group"`
+	DLQTopic       string   `env:"KAFKA_DLQ_TOPIC" env-default:"orders.dlq"`
+	Workers        int      `env:"KAFKA_WORKERS" env-default:"8"`
+	BatchSize      int      `env:"KAFKA_BATCH_SIZE" env-default:"100"`
+	StatusTopic    string   `env:"KAFKA_STATUS_TOPIC" env-default:"orders.status"`
+	StatusGroupID  string   `env:"KAFKA_STATUS_GROUP_ID" env-default:"wb-status-group"`
+	StatusDLQTopic string   `env:"KAFKA_STATUS_DLQ_TOPIC" env-default:"orders.status.dlq"`
+	CreatedTopic   string   `env:"KAFKA_CREATED_TOPIC" env-default:"orders.created"`
+}
+
+type WarmupConfig struct {
+	Enabled     bool          `env:"WARMUP_ENABLED" env-default:"true"`
+	Limit       int           `env:"WARMUP_LIMIT" env-default:"1000"`
+	MaxAge      time.Duration `env:"WARMUP_MAX_AGE" env-default:"0s"`
+	Concurrency int           `env:"WARMUP_CONCURRENCY" env-default:"8"`
+	Timeout     time.Duration `env:"WARMUP_TIMEOUT" env-default:"30s"`
+}
+
+type OutboxConfig struct {
+	PollInterval time.Duration `env:"OUTBOX_POLL_INTERVAL" env-default:"1s"`
+	BatchSize    int           `env:"OUTBOX_BATCH_SIZE" env-default:"100"`
+	Retention    time.Duration `env:"OUTBOX_RETENTION" env-default:"24h"`
+}
+
+type ValidationConfig struct {
+	WarnRules []string `env:"VALIDATION_WARN_RULES" env-separator:","`
+}
+
+// AdminConfig lists the bearer tokens accepted by the /admin routes as
+// principal:token pairs. The admin routes are disabled without tokens.
+type AdminConfig struct {
+	Tokens map[string]string `env:"ADMIN_TOKENS" env-separator:","`
+}
+
+type IngestConfig struct {
+	Mode           string        `env:"INGEST_MODE" env-default:"direct"`
+	IdempotencyTTL time.Duration `env:"INGEST_IDEMPOTENCY_TTL" env-default:"24h"`
+	BatchLimit     int           `env:"INGEST_BATCH_LIMIT" env-default:"500"`
+}
+
+// LedgerConfig controls how long ingestion ledger entries are kept. The
+// retention should exceed the Kafka topic retention so replays are still
+// detected. Zero keeps entries forever.
+type LedgerConfig struct {
+	Retention time.Duration `env:"LEDGER_RETENTION" env-default:"720h"`
+	Interval  time.Duration `env:"LEDGER_CLEANUP_INTERVAL" env-default:"1h"`
+	BatchSize int           `env:"LEDGER_CLEANUP_BATCH_SIZE" env-default:"1000"`
+}
+
+type ArchiveConfig struct {
+	Enabled       bool          `env:"ARCHIVE_ENABLED" env-default:"false"`
+	RetentionDays int           `env:"ARCHIVE_RETENTION_DAYS" env-default:"90"`
+	Interval      time.Duration `env:"ARCHIVE_INTERVAL" env-default:"1h"`
+	BatchSize     int           `env:"ARCHIVE_BATCH_SIZE" env-default:"500"`
+}
+
+type PartitionConfig struct {
+	Enabled         bool          `env:"PARTITION_MAINTENANCE_ENABLED" env-default:"true"`
+	PremakeMonths   int           `env:"PARTITION_PREMAKE_MONTHS" env-default:"3"`
+	RetentionMonths int           `env:"PARTITION_RETENTION_MONTHS" env-default:"0"`
+	Interval        time.Duration `env:"PARTITION_MAINTENANCE_INTERVAL" env-default:"24h"`
 }
 
 type OtelConfig struct {
@@ -61,5 +175,9 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("error reading env: %w", err)
 	}
 
+	if !cfg.Orders.ConflictPolicy.Valid() {
+		return nil, fmt.Errorf("invalid ORDER_CONFLICT_POLICY %q", cfg.Orders.ConflictPolicy)
+	}
+
 	return &cfg, nil
 }
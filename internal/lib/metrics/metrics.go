@@ -12,11 +12,25 @@ import (
 )
 
 type Metrics struct {
-	OrdersCreated   prometheus.Counter
-	CacheHits       prometheus.Counter
-	CacheMisses     prometheus.Counter
-	requestDuration *prometheus.HistogramVec
-	requestCount    *prometheus.CounterVec
+	OrdersCreated     prometheus.Counter
+	OrderSaves        *prometheus.CounterVec
+	IngestedMessages  *prometheus.CounterVec
+	IngestionsPurged  prometheus.Counter
+	RuleViolations    *prometheus.CounterVec
+	CacheHits         prometheus.Counter
+	CacheMisses       prometheus.Counter
+	CacheTierHits     *prometheus.CounterVec
+	CacheTierMisses   *prometheus.CounterVec
+	CoalescedLoads    prometheus.Counter
+	NegativeCacheHits prometheus.Counter
+	WarmupOrders      prometheus.Counter
+	WarmupDone        prometheus.Gauge
+	StatusEvents      *prometheus.CounterVec
+	OrderErasures     *prometheus.CounterVec
+	OrdersArchived    prometheus.Counter
+	RedisDegraded     prometheus.Gauge
+	requestDuration   *prometheus.HistogramVec
+	requestCount      *prometheus.CounterVec
 }
 
 func New() *Metrics {
@@ -25,6 +39,22 @@ func New() *Metrics {
 			Name: "wb_orders_created_total",
 			Help: "Total number of created orders",
 		}),
+		OrderSaves: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_order_saves_total",
+			Help: "Total number of order saves by result",
+		}, []string{"result"}),
+		IngestedMessages: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_ingested_messages_total",
+			Help: "Total number of Kafka order messages by ingestion result",
+		}, []string{"result"}),
+		IngestionsPurged: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "wb_ingestion_ledger_purged_total",
+			Help: "Total number of ingestion ledger entries removed by retention",
+		}),
+		RuleViolations: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_validation_rule_violations_total",
+			Help: "Total number of business rule violations by rule and severity",
+		}, []string{"rule", "severity"}),
 		CacheHits: promauto.NewCounter(prometheus.CounterOpts{
 			Name: "wb_cache_hits_total",
 			Help: "Total number of cache hits",
@@ -33,6 +63,46 @@ func New() *Metrics {
 			Name: "wb_cache_misses_total",
 			Help: "Total number of cache misses",
 		}),
+		CacheTierHits: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_cache_tier_hits_total",
+			Help: "Total number of cache hits per cache tier",
+		}, []string{"tier"}),
+		CacheTierMisses: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_cache_tier_misses_total",
+			Help: "Total number of cache misses per cache tier",
+		}, []string{"tier"}),
+		CoalescedLoads: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "wb_order_loads_coalesced_total",
+			Help: "Total number of order lookups that shared an in-flight database load",
+		}),
+		NegativeCacheHits: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "wb_cache_negative_hits_total",
+			Help: "Total number of lookups answered by a cached not-found entry",
+		}),
+		WarmupOrders: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "wb_cache_warmup_orders_total",
+			Help: "Total number of orders loaded into the cache during warm-up",
+		}),
+		WarmupDone: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "wb_cache_warmup_done",
+			Help: "Whether the startup cache warm-up has finished (1) or is still running (0)",
+		}),
+		StatusEvents: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_status_events_total",
+			Help: "Total number of consumed order status events by result",
+		}, []string{"result"}),
+		OrderErasures: promauto.NewCounterVec(prometheus.CounterOpts{
+			Name: "wb_order_erasures_total",
+			Help: "Total number of erased orders by mode",
+		}, []string{"mode"}),
+		OrdersArchived: promauto.NewCounter(prometheus.CounterOpts{
+			Name: "wb_orders_archived_total",
+			Help: "Total number of orders moved to the archive",
+		}),
+		RedisDegraded: promauto.NewGauge(prometheus.GaugeOpts{
+			Name: "wb_redis_degraded",
+			Help: "1 when Redis is unreachable and the cache runs in degraded mode",
+		}),
 		requestDuration: promauto.NewHistogramVec(prometheus.HistogramOpts{
 			Name:    "http_request_duration_seconds",
 			Help:    "Duration of HTTP requests",
@@ -72,12 +142,54 @@ func NewTestMetrics() *Metrics {
 		OrdersCreated: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "test_orders_created",
 		}),
+		OrderSaves: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_order_saves",
+		}, []string{"result"}),
+		IngestedMessages: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_ingested_messages",
+		}, []string{"result"}),
+		IngestionsPurged: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_ingestions_purged",
+		}),
+		RuleViolations: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_rule_violations",
+		}, []string{"rule", "severity"}),
 		CacheHits: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "test_cache_hits",
 		}),
 		CacheMisses: prometheus.NewCounter(prometheus.CounterOpts{
 			Name: "test_cache_misses",
 		}),
+		CacheTierHits: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_cache_tier_hits",
+		}, []string{"tier"}),
+		CacheTierMisses: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_cache_tier_misses",
+		}, []string{"tier"}),
+		CoalescedLoads: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_coalesced_loads",
+		}),
+		NegativeCacheHits: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_negative_cache_hits",
+		}),
+		WarmupOrders: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_warmup_orders",
+		}),
+		WarmupDone: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "test_warmup_done",
+		}),
+		StatusEvents: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_status_events",
+		}, []string{"result"}),
+		OrderErasures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "test_order_erasures",
+		}, []string{"mode"}),
+		OrdersArchived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "test_orders_archived",
+		}),
+		RedisDegraded: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "test_redis_degraded",
+		}),
 		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
 			Name: "test_request_duration",
 		}, []string{"method", "path"}),
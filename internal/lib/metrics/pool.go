@@ -0,0 +1,86 @@
+package metrics
+
+import (
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// PoolCollector exports pgxpool statistics, read at scrape time, for a set
+// of named pools.
+type PoolCollector struct {
+	pools map[string]*pgxpool.Pool
+
+	acquiredConns     *prometheus.Desc
+	idleConns         *prometheus.Desc
+	constructingConns *prometheus.Desc
+	totalConns        *prometheus.Desc
+	maxConns          *prometheus.Desc
+	acquires          *prometheus.Desc
+	acquireDuration   *prometheus.Desc
+	emptyAcquires     *prometheus.Desc
+	canceledAcquires  *prometheus.Desc
+	newConns          *prometheus.Desc
+	lifetimeDestroys  *prometheus.Desc
+	idleDestroys      *prometheus.Desc
+}
+
+func NewPoolCollector(pools map[string]*pgxpool.Pool) *PoolCollector {
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc("wb_db_pool_"+name, help, []string{"pool"}, nil)
+	}
+	return &PoolCollector{
+		pools:             pools,
+		acquiredConns:     desc("acquired_conns", "Number of connections currently in use"),
+		idleConns:         desc("idle_conns", "Number of idle connections"),
+		constructingConns: desc("constructing_conns", "Number of connections being established"),
+		totalConns:        desc("total_conns", "Total number of connections in the pool"),
+		maxConns:          desc("max_conns", "Maximum size of the pool"),
+		acquires:          desc("acquires_total", "Total number of successful connection acquires"),
+		acquireDuration:   desc("acquire_duration_seconds_total", "Total time spent acquiring connections"),
+		emptyAcquires:     desc("empty_acquires_total", "Total number of acquires that waited for a connection"),
+		canceledAcquires:  desc("canceled_acquires_total", "Total number of acquires canceled by their context"),
+		newConns:          desc("new_conns_total", "Total number of connections opened"),
+		lifetimeDestroys:  desc("max_lifetime_destroys_total", "Total number of connections closed for exceeding MaxConnLifetime"),
+		idleDestroys:      desc("max_idle_destroys_total", "Total number of connections closed for exceeding MaxConnIdleTime"),
+	}
+}
+
+func (c *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.acquiredConns
+	ch <- c.idleConns
+	ch <- c.constructingConns
+	ch <- c.totalConns
+	ch <- c.maxConns
+	ch <- c.acquires
+	ch <- c.acquireDuration
+	ch <- c.emptyAcquires
+	ch <- c.canceledAcquires
+	ch <- c.newConns
+	ch <- c.lifetimeDestroys
+	ch <- c.idleDestroys
+}
+
+func (c *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	for name, pool := range c.pools {
+		st := pool.Stat()
+		gauge := func(d *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.GaugeValue, v, name)
+		}
+		counter := func(d *prometheus.Desc, v float64) {
+			ch <- prometheus.MustNewConstMetric(d, prometheus.CounterValue, v, name)
+		}
+
+		gauge(c.acquiredConns, float64(st.AcquiredConns()))
+		gauge(c.idleConns, float64(st.IdleConns()))
+		gauge(c.constructingConns, float64(st.ConstructingConns()))
+		gauge(c.totalConns, float64(st.TotalConns()))
+		gauge(c.maxConns, float64(st.MaxConns()))
+		counter(c.acquires, float64(st.AcquireCount()))
+		counter(c.acquireDuration, st.AcquireDuration().Seconds())
+		counter(c.emptyAcquires, float64(st.EmptyAcquireCount()))
+		counter(c.canceledAcquires, float64(st.CanceledAcquireCount()))
+		counter(c.newConns, float64(st.NewConnsCount()))
+		counter(c.lifetimeDestroys, float64(st.MaxLifetimeDestroyCount()))
+		counter(c.idleDestroys, float64(st.MaxIdleDestroyCount()))
+	}
+}
@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/go-playground/validator/v10"
+)
+
+const (
+	LocaleEN = "en"
+	LocaleRU = "ru"
+)
+
+var messages = map[string]map[string]string{
+	LocaleEN: {
+		"tag:required":  "is required",
+		"tag:email":     "must be a valid email address",
+		"tag:len":       "must be exactly %s characters long",
+		"tag:gt":        "must be greater than %s",
+		"tag:gte":       "must be greater than or equal to %s",
+		"tag:lte":       "must be less than or equal to %s",
+		"tag:oneof":     "must be one of: %s",
+		"tag:min_items": "must contain more than %s items",
+		"tag":           "is invalid (%s)",
+
+		RuleGoodsTotal:       "goods_total %d does not match the sum of item total_price %d",
+		RuleAmount:           "amount %d does not match goods_total + delivery_cost + custom_fee = %d",
+		RuleItemTrackNumber:  "track_number %q differs from the order track_number %q",
+		RuleTransactionOwner: "transaction %q does not match order_uid %q",
+	},
+	LocaleRU: {
+		"tag:required":  "обязательное поле",
+		"tag:email":     "должен быть корректным email",
+		"tag:len":       "длина должна быть ровно %s символов",
+		"tag:gt":        "должно быть больше %s",
+		"tag:gte":       "должно быть не меньше %s",
+		"tag:lte":       "должно быть не больше %s",
+		"tag:oneof":     "допустимые значения: %s",
+		"tag:min_items": "должно содержать больше %s элементов",
+		"tag":           "недопустимое значение (%s)",
+
+		RuleGoodsTotal:       "goods_total %d не совпадает с суммой total_price позиций %d",
+		RuleAmount:           "amount %d не равен goods_total + delivery_cost + custom_fee = %d",
+		RuleItemTrackNumber:  "track_number %q позиции отличается от track_number заказа %q",
+		RuleTransactionOwner: "transaction %q не совпадает с order_uid %q",
+	},
+}
+
+// locale falls back to English for anything but the supported locales.
+func locale(l string) string {
+	if _, ok := messages[l]; ok {
+		return l
+	}
+	return LocaleEN
+}
+
+func message(l, key string, args ...any) (string, bool) {
+	format, ok := messages[locale(l)][key]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf(format, args...), true
+}
+
+func tagMessage(l string, fe validator.FieldError) string {
+	key := "tag:" + fe.Tag()
+	if fe.Tag() == "gt" && fe.Kind() == reflect.Slice {
+		key = "tag:min_items"
+	}
+	var args []any
+	if fe.Param() != "" {
+		args = append(args, fe.Param())
+	}
+	if msg, ok := message(l, key, args...); ok {
+		return msg
+	}
+	msg, _ := message(l, "tag", fe.Tag())
+	return msg
+}
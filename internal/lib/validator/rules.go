@@ -0,0 +1,103 @@
+package validator
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+)
+
+type Severity string
+
+const (
+	SeverityReject Severity = "reject"
+	SeverityWarn   Severity = "warn"
+)
+
+const (
+	RuleGoodsTotal       = "goods_total_matches_items"
+	RuleAmount           = "amount_matches_totals"
+	RuleItemTrackNumber  = "item_track_number_matches_order"
+	RuleTransactionOwner = "transaction_matches_order_uid"
+)
+
+// Rule is a named check over the whole order. Check reports the violated
+// fields; the rule name and severity are filled in by the validator. Custom
+// rules set Message themselves, built-in ones are localized from args.
+type Rule struct {
+	Name     string
+	Severity Severity
+	Check    func(order *model.Order) []Violation
+}
+
+type Violation struct {
+	Field    string   `json:"field"`
+	Rule     string   `json:"rule"`
+	Message  string   `json:"message"`
+	Severity Severity `json:"severity"`
+
+	args []any
+}
+
+// Error lists every violation of a rejected order. Messages are in Locale.
+type Error struct {
+	Locale     string
+	Violations []Violation
+}
+
+func (e *Error) Error() string {
+	parts := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		parts = append(parts, fmt.Sprintf("%s: %s (%s)", v.Field, v.Message, v.Rule))
+	}
+	return "validation failed: " + strings.Join(parts, "; ")
+}
+
+func DefaultRules() []Rule {
+	return []Rule{
+		{Name: RuleGoodsTotal, Severity: SeverityReject, Check: checkGoodsTotal},
+		{Name: RuleAmount, Severity: SeverityReject, Check: checkAmount},
+		{Name: RuleItemTrackNumber, Severity: SeverityReject, Check: checkItemTrackNumbers},
+		{Name: RuleTransactionOwner, Severity: SeverityReject, Check: checkTransaction},
+	}
+}
+
+func checkGoodsTotal(order *model.Order) []Violation {
+	sum := 0
+	for _, item := range order.Items {
+		sum += item.TotalPrice
+	}
+	if order.Payment.GoodsTotal == sum {
+		return nil
+	}
+	return []Violation{{Field: "payment.goods_total", args: []any{order.Payment.GoodsTotal, sum}}}
+}
+
+func checkAmount(order *model.Order) []Violation {
+	p := order.Payment
+	want := p.GoodsTotal + p.DeliveryCost + p.CustomFee
+	if p.Amount == want {
+		return nil
+	}
+	return []Violation{{Field: "payment.amount", args: []any{p.Amount, want}}}
+}
+
+func checkItemTrackNumbers(order *model.Order) []Violation {
+	var violations []Violation
+	for i, item := range order.Items {
+		if item.TrackNumber != order.TrackNumber {
+			violations = append(violations, Violation{
+				Field: fmt.Sprintf("items[%d].track_number", i),
+				args:  []any{item.TrackNumber, order.TrackNumber},
+			})
+		}
+	}
+	return violations
+}
+
+func checkTransaction(order *model.Order) []Violation {
+	if order.Payment.Transaction == order.OrderUID {
+		return nil
+	}
+	return []Violation{{Field: "payment.transaction", args: []any{order.Payment.Transaction, order.OrderUID}}}
+}
@@ -1,16 +1,164 @@
 package validator
 
 import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/MikebangSfilya/wb/internal/lib/metrics"
 	"github.com/MikebangSfilya/wb/internal/model"
 	"github.com/go-playground/validator/v10"
 )
 
-var validate *validator.Validate
+var (
+	validate   *validator.Validate
+	rules      []Rule
+	severities map[string]Severity
+	m          *metrics.Metrics
+)
+
+type Option func()
+
+// WithSeverity overrides the severity of a registered rule.
+func WithSeverity(rule string, severity Severity) Option {
+	return func() {
+		severities[rule] = severity
+	}
+}
+
+// WarnRules returns a WithSeverity(name, SeverityWarn) option for each name.
+// Names must belong to DefaultRules, so a typo is reported instead of being
+// silently ignored.
+func WarnRules(names []string) ([]Option, error) {
+	known := make(map[string]bool)
+	for _, rule := range DefaultRules() {
+		known[rule.Name] = true
+	}
 
-func Init() {
+	opts := make([]Option, 0, len(names))
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !known[name] {
+			return nil, fmt.Errorf("unknown validation rule %q", name)
+		}
+		opts = append(opts, WithSeverity(name, SeverityWarn))
+	}
+	return opts, nil
+}
+
+// WithMetrics counts business rule violations per rule.
+func WithMetrics(metrics *metrics.Metrics) Option {
+	return func() {
+		m = metrics
+	}
+}
+
+func Init(opts ...Option) {
 	validate = validator.New()
+	validate.RegisterTagNameFunc(func(f reflect.StructField) string {
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		if name == "-" {
+			return ""
+		}
+		return name
+	})
+
+	rules = DefaultRules()
+	severities = make(map[string]Severity)
+	m = nil
+	for _, opt := range opts {
+		opt()
+	}
+}
+
+// Register adds a business rule that is checked after the struct tags.
+func Register(rule Rule) {
+	rules = append(rules, rule)
+}
+
+// Check returns every struct tag and business rule violation of the order.
+// Messages are written in the order locale.
+func Check(order *model.Order) []Violation {
+	var violations []Violation
+
+	if err := validate.Struct(order); err != nil {
+		var errs validator.ValidationErrors
+		if !errors.As(err, &errs) {
+			return []Violation{{Rule: "struct", Message: err.Error(), Severity: SeverityReject}}
+		}
+		for _, fe := range errs {
+			violations = append(violations, Violation{
+				Field:    fieldPath(fe.Namespace()),
+				Rule:     fe.Tag(),
+				Message:  tagMessage(order.Locale, fe),
+				Severity: SeverityReject,
+			})
+		}
+	}
+
+	for _, rule := range rules {
+		severity := rule.Severity
+		if s, ok := severities[rule.Name]; ok {
+			severity = s
+		}
+		for _, v := range rule.Check(order) {
+			v.Rule = rule.Name
+			v.Severity = severity
+			if msg, ok := message(order.Locale, rule.Name, v.args...); ok {
+				v.Message = msg
+			}
+			violations = append(violations, v)
+			if m != nil {
+				m.RuleViolations.WithLabelValues(rule.Name, string(severity)).Inc()
+			}
+		}
+	}
+	return violations
+}
+
+// Validate returns an *Error listing all violations if at least one of them
+// rejects the order. Orders with warnings only are accepted and the warnings
+// are returned so the caller can report them.
+func Validate(order *model.Order) ([]Violation, error) {
+	violations := Check(order)
+	var warnings []Violation
+	for _, v := range violations {
+		if v.Severity == SeverityReject {
+			return nil, &Error{Locale: locale(order.Locale), Violations: violations}
+		}
+		warnings = append(warnings, v)
+	}
+	return warnings, nil
+}
+
+func ValidateStatusEvent(event *model.StatusEvent) error {
+	err := validate.Struct(event)
+	var errs validator.ValidationErrors
+	if !errors.As(err, &errs) {
+		return err
+	}
+
+	verr := &Error{Locale: LocaleEN}
+	for _, fe := range errs {
+		verr.Violations = append(verr.Violations, Violation{
+			Field:    fieldPath(fe.Namespace()),
+			Rule:     fe.Tag(),
+			Message:  tagMessage(LocaleEN, fe),
+			Severity: SeverityReject,
+		})
+	}
+	return verr
 }
 
-func Validate(order *model.Order) error {
-	return validate.Struct(order)
+// fieldPath turns "Order.items[0].price" into "items[0].price".
+func fieldPath(namespace string) string {
+	_, path, found := strings.Cut(namespace, ".")
+	if !found {
+		return namespace
+	}
+	return path
 }
@@ -0,0 +1,203 @@
+package validator
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/metrics"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testOrder() *model.Order {
+	return &model.Order{
+		OrderUID:        "b563feb7b2b84b6test",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "b563feb7b2b84b6test",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func TestValidate_Rules(t *testing.T) {
+	tests := []struct {
+		name       string
+		modify     func(o *model.Order)
+		wantFields []string
+		wantRules  []string
+	}{
+		{
+			name:   "valid",
+			modify: func(o *model.Order) {},
+		},
+		{
+			name:       "goods_total",
+			modify:     func(o *model.Order) { o.Items[0].TotalPrice = 300 },
+			wantFields: []string{"payment.goods_total"},
+			wantRules:  []string{RuleGoodsTotal},
+		},
+		{
+			name:       "amount",
+			modify:     func(o *model.Order) { o.Payment.CustomFee = 10 },
+			wantFields: []string{"payment.amount"},
+			wantRules:  []string{RuleAmount},
+		},
+		{
+			name:       "item_track_number",
+			modify:     func(o *model.Order) { o.Items[0].TrackNumber = "OTHER" },
+			wantFields: []string{"items[0].track_number"},
+			wantRules:  []string{RuleItemTrackNumber},
+		},
+		{
+			name:       "transaction",
+			modify:     func(o *model.Order) { o.Payment.Transaction = "other" },
+			wantFields: []string{"payment.transaction"},
+			wantRules:  []string{RuleTransactionOwner},
+		},
+		{
+			name: "tags_and_rules_together",
+			modify: func(o *model.Order) {
+				o.Delivery.Email = "not-an-email"
+				o.Payment.Transaction = "other"
+			},
+			wantFields: []string{"delivery.email", "payment.transaction"},
+			wantRules:  []string{"email", RuleTransactionOwner},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			Init()
+			order := testOrder()
+			tt.modify(order)
+
+			_, err := Validate(order)
+			if tt.wantFields == nil {
+				assert.NoError(t, err)
+				return
+			}
+
+			var verr *Error
+			require.True(t, errors.As(err, &verr))
+			var fields, rules []string
+			for _, v := range verr.Violations {
+				fields = append(fields, v.Field)
+				rules = append(rules, v.Rule)
+				assert.Equal(t, SeverityReject, v.Severity)
+			}
+			assert.Equal(t, tt.wantFields, fields)
+			assert.Equal(t, tt.wantRules, rules)
+		})
+	}
+}
+
+func TestValidate_WarnSeverity(t *testing.T) {
+	m := metrics.NewTestMetrics()
+	Init(WithMetrics(m), WithSeverity(RuleTransactionOwner, SeverityWarn))
+
+	order := testOrder()
+	order.Payment.Transaction = "other"
+
+	warnings, err := Validate(order)
+	assert.NoError(t, err)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, RuleTransactionOwner, warnings[0].Rule)
+	assert.Equal(t, SeverityWarn, warnings[0].Severity)
+
+	violations := Check(order)
+	require.Len(t, violations, 1)
+	assert.Equal(t, SeverityWarn, violations[0].Severity)
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.RuleViolations.WithLabelValues(RuleTransactionOwner, "warn")))
+}
+
+func TestWarnRules(t *testing.T) {
+	opts, err := WarnRules([]string{RuleAmount, " " + RuleGoodsTotal, ""})
+	require.NoError(t, err)
+	assert.Len(t, opts, 2)
+
+	_, err = WarnRules([]string{"amount_matches_total"})
+	assert.ErrorContains(t, err, `unknown validation rule "amount_matches_total"`)
+}
+
+func TestRegister(t *testing.T) {
+	Init()
+	Register(Rule{
+		Name:     "no_test_customers",
+		Severity: SeverityReject,
+		Check: func(o *model.Order) []Violation {
+			if o.CustomerID == "test" {
+				return []Violation{{Field: "customer_id", Message: "test customers are not allowed"}}
+			}
+			return nil
+		},
+	})
+
+	var verr *Error
+	_, err := Validate(testOrder())
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, "no_test_customers", verr.Violations[0].Rule)
+}
+
+func TestValidate_Localized(t *testing.T) {
+	Init()
+
+	order := testOrder()
+	order.Locale = "ru"
+	order.Payment.Currency = "RUBL"
+	order.Payment.Transaction = "other"
+
+	var verr *Error
+	_, err := Validate(order)
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, LocaleRU, verr.Locale)
+	require.Len(t, verr.Violations, 2)
+	assert.Equal(t, "payment.currency", verr.Violations[0].Field)
+	assert.Equal(t, "длина должна быть ровно 3 символов", verr.Violations[0].Message)
+	assert.Equal(t, `transaction "other" не совпадает с order_uid "b563feb7b2b84b6test"`, verr.Violations[1].Message)
+
+	order.Locale = "de"
+	_, err = Validate(order)
+	require.True(t, errors.As(err, &verr))
+	assert.Equal(t, LocaleEN, verr.Locale)
+	assert.Equal(t, "must be exactly 3 characters long", verr.Violations[0].Message)
+}
@@ -0,0 +1,32 @@
+package model
+
+import "time"
+
+// ErasureMode selects how personal data of an order is removed.
+type ErasureMode string
+
+const (
+	// ErasureDelete removes the order with all of its rows.
+	ErasureDelete ErasureMode = "delete"
+	// ErasureAnonymize blanks the delivery contact data, customer_id and the
+	// reasons of status changes and keeps the order, payment and items.
+	ErasureAnonymize ErasureMode = "anonymize"
+)
+
+func (m ErasureMode) Valid() bool {
+	switch m {
+	case ErasureDelete, ErasureAnonymize:
+		return true
+	}
+	return false
+}
+
+// Erasure is the audit record of an erasure request.
+type Erasure struct {
+	ID          int64       `json:"id"`
+	OrderUID    string      `json:"order_uid"`
+	Mode        ErasureMode `json:"mode"`
+	RequestedBy string      `json:"requested_by"`
+	Reason      string      `json:"reason,omitempty"`
+	ErasedAt    time.Time   `json:"erased_at"`
+}
@@ -2,4 +2,14 @@ package model
 
 import "errors"
 
-var ErrNotFound = errors.New("not found")
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidStatus     = errors.New("invalid status")
+	ErrInvalidTransition = errors.New("invalid status transition")
+	ErrStatusConflict    = errors.New("status was changed concurrently")
+	ErrDuplicateEvent    = errors.New("event already applied")
+	ErrOrderConflict     = errors.New("order already exists with different content")
+	ErrVersionConflict   = errors.New("order version conflict")
+	ErrInvalidErasure    = errors.New("invalid erasure request")
+)
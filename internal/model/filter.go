@@ -0,0 +1,40 @@
+package model
+
+import "time"
+
+type OrderSort string
+
+const (
+	SortCreatedDesc OrderSort = "-date_created"
+	SortCreatedAsc  OrderSort = "date_created"
+	SortAmountDesc  OrderSort = "-amount"
+	SortAmountAsc   OrderSort = "amount"
+)
+
+func (s OrderSort) Valid() bool {
+	switch s {
+	case SortCreatedDesc, SortCreatedAsc, SortAmountDesc, SortAmountAsc:
+		return true
+	}
+	return false
+}
+
+type OrderFilter struct {
+	CustomerID      string
+	TrackNumber     string
+	DeliveryService string
+	CreatedFrom     time.Time
+	CreatedTo       time.Time
+	PaymentProvider string
+	PaymentBank     string
+	ItemBrand       string
+	ItemNmID        int
+	Sort            OrderSort
+	Limit           int
+	Cursor          string
+}
+
+type OrderPage struct {
+	Orders     []*Order `json:"orders"`
+	NextCursor string   `json:"next_cursor,omitempty"`
+}
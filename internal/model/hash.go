@@ -0,0 +1,23 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+)
+
+// ContentHash returns a digest of the order payload. Fields managed by the
+// service itself (status, version and the archive marker) do not take part in it.
+func (o *Order) ContentHash() (string, error) {
+	c := *o
+	c.Status = ""
+	c.Version = 0
+	c.Archived = false
+
+	data, err := json.Marshal(&c)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,22 @@
+package model
+
+import "time"
+
+// MessageSource identifies the Kafka message an order was read from.
+type MessageSource struct {
+	Topic       string
+	Partition   int
+	Offset      int64
+	PayloadHash string
+}
+
+type IngestionRecord struct {
+	Topic       string     `json:"topic"`
+	Partition   int        `json:"partition"`
+	Offset      int64      `json:"offset"`
+	OrderUID    string     `json:"order_uid"`
+	PayloadHash string     `json:"payload_hash"`
+	SaveStatus  SaveStatus `json:"save_status"`
+	Conflicting bool       `json:"conflicting"`
+	ProcessedAt time.Time  `json:"processed_at"`
+}
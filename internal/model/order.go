@@ -3,20 +3,30 @@ package model
 import "time"
 
 type Order struct {
-	OrderUID          string    `json:"order_uid" validate:"required"`
-	TrackNumber       string    `json:"track_number" validate:"required"`
-	Entry             string    `json:"entry" validate:"required"`
-	Delivery          Delivery  `json:"delivery" validate:"required"`
-	Payment           Payment   `json:"payment" validate:"required"`
-	Items             []Item    `json:"items" validate:"required,gt=0,dive"`
-	Locale            string    `json:"locale" validate:"omitempty,oneof=ru en"`
-	InternalSignature string    `json:"internal_signature" validate:"omitempty"`
-	CustomerID        string    `json:"customer_id" validate:"required"`
-	DeliveryService   string    `json:"delivery_service" validate:"required"`
-	Shardkey          string    `json:"shardkey" validate:"required"`
-	SmID              int       `json:"sm_id" validate:"required,gte=0"`
-	DateCreated       time.Time `json:"date_created" validate:"required"`
-	OofShard          string    `json:"oof_shard" validate:"required"`
+	OrderUID          string      `json:"order_uid" validate:"required"`
+	TrackNumber       string      `json:"track_number" validate:"required"`
+	Entry             string      `json:"entry" validate:"required"`
+	Delivery          Delivery    `json:"delivery" validate:"required"`
+	Payment           Payment     `json:"payment" validate:"required"`
+	Items             []Item      `json:"items" validate:"required,gt=0,dive"`
+	Locale            string      `json:"locale" validate:"omitempty,oneof=ru en"`
+	InternalSignature string      `json:"internal_signature" validate:"omitempty"`
+	CustomerID        string      `json:"customer_id" validate:"required"`
+	DeliveryService   string      `json:"delivery_service" validate:"required"`
+	Shardkey          string      `json:"shardkey" validate:"required"`
+	SmID              int         `json:"sm_id" validate:"required,gte=0"`
+	DateCreated       time.Time   `json:"date_created" validate:"required"`
+	OofShard          string      `json:"oof_shard" validate:"required"`
+	Status            OrderStatus `json:"status,omitempty" validate:"omitempty,oneof=created paid assembling shipped delivered cancelled returned"`
+	Version           int         `json:"version,omitempty" validate:"gte=0"`
+	Archived          bool        `json:"archived,omitempty"`
+}
+
+// Clone returns a copy of the order that shares no memory with it.
+func (o *Order) Clone() *Order {
+	c := *o
+	c.Items = append([]Item(nil), o.Items...)
+	return &c
 }
 
 type Delivery struct {
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const EventOrderCreated = "order.created"
+
+// OutboxMessage is an event stored in the same transaction as the change it
+// describes and published to Kafka later by the outbox relay.
+type OutboxMessage struct {
+	ID        int64
+	EventType string
+	Key       string
+	Payload   []byte
+	Attempts  int
+	CreatedAt time.Time
+}
+
+type OrderEvent struct {
+	Type       string    `json:"type"`
+	OrderUID   string    `json:"order_uid"`
+	Version    int       `json:"version"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Order      *Order    `json:"order"`
+}
@@ -0,0 +1,70 @@
+package model
+
+type SaveStatus string
+
+const (
+	SaveInserted  SaveStatus = "inserted"
+	SaveUpdated   SaveStatus = "updated"
+	SaveDuplicate SaveStatus = "duplicate"
+	SaveIgnored   SaveStatus = "ignored"
+	SaveConflict  SaveStatus = "conflict"
+	// SaveErased means the order was erased and is not stored again.
+	SaveErased SaveStatus = "erased"
+	SaveFailed SaveStatus = "failed"
+)
+
+// Changed reports whether the stored order now matches the saved payload.
+func (s SaveStatus) Changed() bool {
+	return s == SaveInserted || s == SaveUpdated
+}
+
+// Conflicting reports whether the payload differed from the stored order.
+func (s SaveStatus) Conflicting() bool {
+	return s == SaveUpdated || s == SaveIgnored || s == SaveConflict
+}
+
+type SaveResult struct {
+	OrderUID string
+	Status   SaveStatus
+	// Previous holds the secondary keys the order had before it was replaced.
+	Previous *SecondaryKeys
+	Err      error
+}
+
+// SecondaryKeys are the values an order can be looked up by besides its
+// order_uid.
+type SecondaryKeys struct {
+	TrackNumber string
+	Transaction string
+	Rids        []string
+}
+
+func (o *Order) SecondaryKeys() SecondaryKeys {
+	keys := SecondaryKeys{
+		TrackNumber: o.TrackNumber,
+		Transaction: o.Payment.Transaction,
+		Rids:        make([]string, 0, len(o.Items)),
+	}
+	for _, item := range o.Items {
+		keys.Rids = append(keys.Rids, item.Rid)
+	}
+	return keys
+}
+
+// ConflictPolicy decides what happens when an order is re-sent with an
+// order_uid that is already stored.
+type ConflictPolicy string
+
+const (
+	ConflictIgnore  ConflictPolicy = "ignore"
+	ConflictReplace ConflictPolicy = "replace"
+	ConflictReject  ConflictPolicy = "reject"
+)
+
+func (p ConflictPolicy) Valid() bool {
+	switch p {
+	case ConflictIgnore, ConflictReplace, ConflictReject:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package model
+
+import "time"
+
+type OrderStatus string
+
+const (
+	StatusCreated    OrderStatus = "created"
+	StatusPaid       OrderStatus = "paid"
+	StatusAssembling OrderStatus = "assembling"
+	StatusShipped    OrderStatus = "shipped"
+	StatusDelivered  OrderStatus = "delivered"
+	StatusCancelled  OrderStatus = "cancelled"
+	StatusReturned   OrderStatus = "returned"
+)
+
+func (s OrderStatus) Valid() bool {
+	switch s {
+	case StatusCreated, StatusPaid, StatusAssembling, StatusShipped,
+		StatusDelivered, StatusCancelled, StatusReturned:
+		return true
+	}
+	return false
+}
+
+type StatusChange struct {
+	EventID   string      `json:"event_id,omitempty"`
+	OrderUID  string      `json:"order_uid"`
+	From      OrderStatus `json:"from,omitempty"`
+	To        OrderStatus `json:"to"`
+	Reason    string      `json:"reason,omitempty"`
+	ChangedAt time.Time   `json:"changed_at"`
+}
+
+type StatusEvent struct {
+	EventID    string      `json:"event_id" validate:"required"`
+	OrderUID   string      `json:"order_uid" validate:"required"`
+	Status     OrderStatus `json:"status" validate:"required"`
+	Reason     string      `json:"reason"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
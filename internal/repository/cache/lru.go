@@ -0,0 +1,118 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+)
+
+type entry struct {
+	key       string
+	data      []byte
+	expiresAt time.Time
+}
+
+type LRU struct {
+	mu    sync.Mutex
+	size  int
+	ttl   time.Duration
+	items map[string]*list.Element
+	order *list.List
+	now   func() time.Time
+}
+
+func NewLRU(size int, ttl time.Duration) *LRU {
+	return &LRU{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element, size),
+		order: list.New(),
+		now:   time.Now,
+	}
+}
+
+func (c *LRU) Set(_ context.Context, key string, value any, ttl time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value for key %s: %w", key, err)
+	}
+	if c.ttl > 0 && (ttl <= 0 || c.ttl < ttl) {
+		ttl = c.ttl
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e := &entry{key: key, data: data}
+	if ttl > 0 {
+		e.expiresAt = c.now().Add(ttl)
+	}
+
+	if el, ok := c.items[key]; ok {
+		el.Value = e
+		c.order.MoveToFront(el)
+		return nil
+	}
+
+	c.items[key] = c.order.PushFront(e)
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+	}
+	return nil
+}
+
+func (c *LRU) Get(ctx context.Context, key string, dest any) error {
+	_, err := c.GetWithTTL(ctx, key, dest)
+	return err
+}
+
+// GetWithTTL works like Get and also returns the remaining time to live of
+// the entry, or 0 if it never expires.
+func (c *LRU) GetWithTTL(_ context.Context, key string, dest any) (time.Duration, error) {
+	c.mu.Lock()
+	el, ok := c.items[key]
+	if !ok {
+		c.mu.Unlock()
+		return 0, redis.ErrCacheMiss
+	}
+	e := el.Value.(*entry)
+	var ttl time.Duration
+	if !e.expiresAt.IsZero() {
+		ttl = e.expiresAt.Sub(c.now())
+		if ttl <= 0 {
+			c.removeElement(el)
+			c.mu.Unlock()
+			return 0, redis.ErrCacheMiss
+		}
+	}
+	c.order.MoveToFront(el)
+	c.mu.Unlock()
+
+	return ttl, json.Unmarshal(e.data, dest)
+}
+
+func (c *LRU) Delete(_ context.Context, key string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.items[key]; ok {
+		c.removeElement(el)
+	}
+	return nil
+}
+
+func (c *LRU) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *LRU) removeElement(el *list.Element) {
+	c.order.Remove(el)
+	delete(c.items, el.Value.(*entry).key)
+}
@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/metrics"
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testValue struct {
+	Name string `json:"name"`
+}
+
+func TestLRU(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Set and Get value", func(t *testing.T) {
+		c := NewLRU(2, time.Minute)
+		require.NoError(t, c.Set(ctx, "a", testValue{Name: "a"}, time.Hour))
+
+		var got testValue
+		require.NoError(t, c.Get(ctx, "a", &got))
+		assert.Equal(t, testValue{Name: "a"}, got)
+	})
+
+	t.Run("Evicts least recently used", func(t *testing.T) {
+		c := NewLRU(2, time.Minute)
+		require.NoError(t, c.Set(ctx, "a", "a", 0))
+		require.NoError(t, c.Set(ctx, "b", "b", 0))
+
+		var got string
+		require.NoError(t, c.Get(ctx, "a", &got))
+		require.NoError(t, c.Set(ctx, "c", "c", 0))
+
+		assert.Equal(t, 2, c.Len())
+		assert.ErrorIs(t, c.Get(ctx, "b", &got), redis.ErrCacheMiss)
+		assert.NoError(t, c.Get(ctx, "a", &got))
+		assert.NoError(t, c.Get(ctx, "c", &got))
+	})
+
+	t.Run("Value expires after TTL", func(t *testing.T) {
+		now := time.Now()
+		c := NewLRU(2, time.Minute)
+		c.now = func() time.Time { return now }
+
+		require.NoError(t, c.Set(ctx, "short", "x", time.Second))
+		require.NoError(t, c.Set(ctx, "long", "x", time.Hour))
+
+		now = now.Add(2 * time.Second)
+		var got string
+		assert.ErrorIs(t, c.Get(ctx, "short", &got), redis.ErrCacheMiss)
+		assert.NoError(t, c.Get(ctx, "long", &got))
+
+		now = now.Add(time.Minute)
+		assert.ErrorIs(t, c.Get(ctx, "long", &got), redis.ErrCacheMiss)
+		assert.Equal(t, 0, c.Len())
+	})
+
+	t.Run("Delete key", func(t *testing.T) {
+		c := NewLRU(2, time.Minute)
+		require.NoError(t, c.Set(ctx, "a", "a", 0))
+		require.NoError(t, c.Delete(ctx, "a"))
+
+		var got string
+		assert.ErrorIs(t, c.Get(ctx, "a", &got), redis.ErrCacheMiss)
+	})
+}
+
+func TestTiered(t *testing.T) {
+	ctx := context.Background()
+	m := metrics.NewTestMetrics()
+	remote := NewLRU(10, time.Hour)
+	c := NewTiered(NewLRU(10, time.Minute), remote, m)
+
+	require.NoError(t, remote.Set(ctx, "a", testValue{Name: "a"}, time.Hour))
+
+	var got testValue
+	require.NoError(t, c.Get(ctx, "a", &got))
+	assert.Equal(t, "a", got.Name)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CacheTierHits.WithLabelValues(tierRedis)))
+
+	got = testValue{}
+	require.NoError(t, c.Get(ctx, "a", &got))
+	assert.Equal(t, "a", got.Name)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CacheTierHits.WithLabelValues(tierLocal)))
+
+	require.NoError(t, c.Delete(ctx, "a"))
+	assert.ErrorIs(t, c.Get(ctx, "a", &got), redis.ErrCacheMiss)
+	assert.Equal(t, 1.0, testutil.ToFloat64(m.CacheTierMisses.WithLabelValues(tierRedis)))
+	assert.Equal(t, 2.0, testutil.ToFloat64(m.CacheTierMisses.WithLabelValues(tierLocal)))
+}
+
+func TestTiered_PromotesWithRemoteTTL(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	remote := NewLRU(10, 0)
+	remote.now = func() time.Time { return now }
+	local := NewLRU(10, 0)
+	local.now = func() time.Time { return now }
+	c := NewTiered(local, remote, metrics.NewTestMetrics())
+
+	require.NoError(t, remote.Set(ctx, "a", "a", 10*time.Second))
+	now = now.Add(4 * time.Second)
+
+	var got string
+	require.NoError(t, c.Get(ctx, "a", &got))
+
+	now = now.Add(5 * time.Second)
+	require.NoError(t, local.Get(ctx, "a", &got))
+
+	now = now.Add(2 * time.Second)
+	assert.ErrorIs(t, local.Get(ctx, "a", &got), redis.ErrCacheMiss)
+}
+
+type testBus struct {
+	mu   sync.Mutex
+	subs []func(string)
+}
+
+func (b *testBus) PublishInvalidation(_ context.Context, msg string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, fn := range b.subs {
+		fn(msg)
+	}
+	return nil
+}
+
+func (b *testBus) SubscribeInvalidations(ctx context.Context, fn func(string)) error {
+	b.mu.Lock()
+	b.subs = append(b.subs, fn)
+	b.mu.Unlock()
+	<-ctx.Done()
+	return nil
+}
+
+func (b *testBus) len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+func TestTiered_Invalidation(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	m := metrics.NewTestMetrics()
+	bus := &testBus{}
+	remote := NewLRU(10, time.Hour)
+	localA, localB := NewLRU(10, time.Minute), NewLRU(10, time.Minute)
+	a := NewTiered(localA, remote, m, WithInvalidator(bus))
+	b := NewTiered(localB, remote, m, WithInvalidator(bus))
+	go func() { _ = a.Listen(ctx) }()
+	go func() { _ = b.Listen(ctx) }()
+	require.Eventually(t, func() bool { return bus.len() == 2 }, time.Second, time.Millisecond)
+
+	require.NoError(t, a.Set(ctx, "k", testValue{Name: "v1"}, time.Hour))
+	var got testValue
+	require.NoError(t, b.Get(ctx, "k", &got))
+	assert.Equal(t, 1, localB.Len())
+
+	require.NoError(t, a.Set(ctx, "k", testValue{Name: "v2"}, time.Hour))
+	assert.Equal(t, 1, localA.Len())
+	assert.Equal(t, 0, localB.Len())
+
+	require.NoError(t, b.Get(ctx, "k", &got))
+	assert.Equal(t, "v2", got.Name)
+
+	require.NoError(t, a.Delete(ctx, "k"))
+	assert.Equal(t, 0, localB.Len())
+}
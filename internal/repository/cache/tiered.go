@@ -0,0 +1,137 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/metrics"
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+)
+
+const (
+	tierLocal = "local"
+	tierRedis = "redis"
+)
+
+type Remote interface {
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	GetWithTTL(ctx context.Context, key string, dest any) (time.Duration, error)
+	Delete(ctx context.Context, key string) error
+}
+
+// Invalidator broadcasts changed keys between instances so each can drop its
+// local copy.
+type Invalidator interface {
+	PublishInvalidation(ctx context.Context, msg string) error
+	SubscribeInvalidations(ctx context.Context, fn func(msg string)) error
+}
+
+type Tiered struct {
+	local  *LRU
+	remote Remote
+	m      *metrics.Metrics
+	inv    Invalidator
+	origin string
+}
+
+type Option func(*Tiered)
+
+// WithInvalidator publishes every Set and Delete through inv and drops the
+// local copies of keys changed by other instances while Listen runs. Without
+// it a stale local copy lives until the local TTL expires.
+func WithInvalidator(inv Invalidator) Option {
+	return func(t *Tiered) {
+		t.inv = inv
+	}
+}
+
+func NewTiered(local *LRU, remote Remote, m *metrics.Metrics, opts ...Option) *Tiered {
+	t := &Tiered{
+		local:  local,
+		remote: remote,
+		m:      m,
+		origin: newOrigin(),
+	}
+	for _, opt := range opts {
+		opt(t)
+	}
+	return t
+}
+
+func (t *Tiered) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if err := t.local.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	if err := t.remote.Set(ctx, key, value, ttl); err != nil {
+		return err
+	}
+	t.publish(ctx, key)
+	return nil
+}
+
+func (t *Tiered) Get(ctx context.Context, key string, dest any) error {
+	if err := t.local.Get(ctx, key, dest); err == nil {
+		t.m.CacheTierHits.WithLabelValues(tierLocal).Inc()
+		return nil
+	}
+	t.m.CacheTierMisses.WithLabelValues(tierLocal).Inc()
+
+	ttl, err := t.remote.GetWithTTL(ctx, key, dest)
+	if err != nil {
+		if errors.Is(err, redis.ErrCacheMiss) {
+			t.m.CacheTierMisses.WithLabelValues(tierRedis).Inc()
+		}
+		return err
+	}
+	t.m.CacheTierHits.WithLabelValues(tierRedis).Inc()
+
+	_ = t.local.Set(ctx, key, dest, ttl)
+	return nil
+}
+
+func (t *Tiered) Delete(ctx context.Context, key string) error {
+	_ = t.local.Delete(ctx, key)
+	if err := t.remote.Delete(ctx, key); err != nil {
+		return err
+	}
+	t.publish(ctx, key)
+	return nil
+}
+
+// Listen drops local copies of keys invalidated by other instances until ctx
+// is done.
+func (t *Tiered) Listen(ctx context.Context) error {
+	if t.inv == nil {
+		return nil
+	}
+	return t.inv.SubscribeInvalidations(ctx, func(msg string) {
+		origin, key, ok := strings.Cut(msg, " ")
+		if !ok || origin == t.origin {
+			return
+		}
+		_ = t.local.Delete(ctx, key)
+	})
+}
+
+func (t *Tiered) publish(ctx context.Context, key string) {
+	if t.inv == nil {
+		return
+	}
+	if err := t.inv.PublishInvalidation(ctx, t.origin+" "+key); err != nil {
+		slog.Warn("failed to publish cache invalidation",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
+func newOrigin() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
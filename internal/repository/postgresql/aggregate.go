@@ -0,0 +1,88 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+)
+
+// ReadMode selects how GetOrder loads an order.
+type ReadMode string
+
+const (
+	// ReadJoins loads the order header with joins and its items with a second
+	// query.
+	ReadJoins ReadMode = "joins"
+	// ReadJSON builds the whole order in the database and returns it as a
+	// single JSON value in one round-trip.
+	ReadJSON ReadMode = "json"
+)
+
+func (m ReadMode) Valid() bool {
+	switch m {
+	case ReadJoins, ReadJSON:
+		return true
+	}
+	return false
+}
+
+// WithReadMode sets how GetOrder loads an order. The default is ReadJoins.
+func WithReadMode(mode ReadMode) Option {
+	return func(r *Repository) {
+		r.readMode = mode
+	}
+}
+
+// The keys match the json tags of model.Order so the row can be decoded
+// straight into it.
+const qOrderJSON = `
+	SELECT json_build_object(
+		'order_uid', o.order_uid,
+		'track_number', o.track_number,
+		'entry', o.entry,
+		'locale', o.locale,
+		'internal_signature', COALESCE(o.internal_signature, ''),
+		'customer_id', o.customer_id,
+		'delivery_service', o.delivery_service,
+		'shardkey', o.shardkey,
+		'sm_id', o.sm_id,
+		'date_created', o.date_created,
+		'oof_shard', o.oof_shard,
+		'status', o.status,
+		'version', o.version,
+		'delivery', json_build_object(
+			'name', d.name, 'phone', d.phone, 'zip', d.zip, 'city', d.city,
+			'address', d.address, 'region', d.region, 'email', d.email
+		),
+		'payment', json_build_object(
+			'transaction', p.transaction, 'request_id', p.request_id, 'currency', p.currency,
+			'provider', p.provider, 'amount', p.amount, 'payment_dt', p.payment_dt, 'bank', p.bank,
+			'delivery_cost', p.delivery_cost, 'goods_total', p.goods_total, 'custom_fee', p.custom_fee
+		),
+		'items', COALESCE((
+			SELECT json_agg(json_build_object(
+				'chrt_id', i.chrt_id, 'track_number', i.track_number, 'price', i.price, 'rid', i.rid,
+				'name', i.name, 'sale', i.sale, 'size', i.size, 'total_price', i.total_price,
+				'nm_id', i.nm_id, 'brand', i.brand, 'status', i.status
+			) ORDER BY i.id)
+			FROM items i
+			WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created
+		), '[]'::json)
+	)` + orderJoins + `
+	WHERE o.order_uid = $1
+`
+
+func getOrderJSON(ctx context.Context, db querier, orderUID string) (*model.Order, error) {
+	var data []byte
+	if err := db.QueryRow(ctx, qOrderJSON, orderUID).Scan(&data); err != nil {
+		return nil, fmt.Errorf("failed to query order: %w", err)
+	}
+
+	var o model.Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, fmt.Errorf("failed to decode order: %w", err)
+	}
+	return &o, nil
+}
@@ -0,0 +1,124 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	qInsertArchive = `
+		INSERT INTO orders_archive (order_uid, date_created, data, content_hash)
+			SELECT $1, $2, $3, content_hash FROM orders WHERE order_uid = $1
+		ON CONFLICT (order_uid) DO UPDATE SET
+			date_created = EXCLUDED.date_created,
+			data = EXCLUDED.data,
+			content_hash = EXCLUDED.content_hash,
+			archived_at = now()
+	`
+	// The order_keys row and the status history stay, so the order_uid
+	// remains taken and the history can still be read.
+	qDeleteArchived = `DELETE FROM orders WHERE order_uid = ANY($1)`
+)
+
+// ArchiveOrders moves up to limit orders created before the given time from
+// the order tables into orders_archive and returns their order_uid values.
+func (r *Repository) ArchiveOrders(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	const op = "postgresql.ArchiveOrders"
+
+	ctx, span := r.tr.Start(ctx, "db.archive.orders")
+	defer span.End()
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	q := `SELECT ` + orderColumns + orderJoins + `
+		WHERE o.date_created < $1
+		ORDER BY o.date_created, o.order_uid
+		LIMIT $2
+		FOR UPDATE OF o SKIP LOCKED
+	`
+	rows, err := tx.Query(ctx, q, before, limit)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	orders := make([]*model.Order, 0, limit)
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		orders = append(orders, o)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(orders) == 0 {
+		return nil, nil
+	}
+
+	if err := loadItems(ctx, tx, orders); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	batch := &pgx.Batch{}
+	uids := make([]string, 0, len(orders))
+	for _, o := range orders {
+		data, err := json.Marshal(o)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		batch.Queue(qInsertArchive, o.OrderUID, o.DateCreated, data)
+		uids = append(uids, o.OrderUID)
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if _, err := tx.Exec(ctx, qDeleteArchived, uids); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	span.SetAttributes(attribute.Int("orders", len(uids)))
+	r.wrote(uids...)
+	return uids, nil
+}
+
+func (r *Repository) getArchivedOrder(ctx context.Context, db querier, orderUID string) (*model.Order, error) {
+	ctx, span := r.tr.Start(ctx, "db.select.orders_archive")
+	defer span.End()
+
+	var data []byte
+	err := db.QueryRow(ctx, `SELECT data FROM orders_archive WHERE order_uid = $1`, orderUID).Scan(&data)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, model.ErrNotFound
+		}
+		return nil, err
+	}
+
+	var o model.Order
+	if err := json.Unmarshal(data, &o); err != nil {
+		return nil, err
+	}
+	o.Archived = true
+	return &o, nil
+}
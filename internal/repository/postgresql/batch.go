@@ -0,0 +1,200 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var itemColumns = []string{
+	"order_uid", "chrt_id", "track_number", "price", "rid", "name", "sale",
+	"size", "total_price", "nm_id", "brand", "status", "date_created",
+}
+
+// CreateOrders writes the whole batch in a single transaction: new orders are
+// inserted and orders that already exist are resolved according to policy. If
+// the batch fails as a whole, every order is retried in its own transaction so
+// that the failing ones can be reported individually.
+func (r *Repository) CreateOrders(ctx context.Context, orders []*model.Order, policy model.ConflictPolicy) ([]model.SaveResult, error) {
+	ctx, span := r.tr.Start(ctx, "db.insert.orders.batch")
+	defer span.End()
+
+	return r.saveOrders(ctx, "postgresql.CreateOrders", orders, nil, policy)
+}
+
+// IngestOrders is CreateOrders for orders read from Kafka: srcs[i] is the
+// message of orders[i] and is recorded in the ingestion ledger in the same
+// transaction as the order.
+func (r *Repository) IngestOrders(ctx context.Context, orders []*model.Order, srcs []model.MessageSource, policy model.ConflictPolicy) ([]model.SaveResult, error) {
+	ctx, span := r.tr.Start(ctx, "db.insert.orders.ingest_batch")
+	defer span.End()
+
+	return r.saveOrders(ctx, "postgresql.IngestOrders", orders, srcs, policy)
+}
+
+func (r *Repository) saveOrders(ctx context.Context, op string, orders []*model.Order, srcs []model.MessageSource, policy model.ConflictPolicy) ([]model.SaveResult, error) {
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Int("orders", len(orders)))
+
+	source := func(i int) *model.MessageSource {
+		if srcs == nil {
+			return nil
+		}
+		return &srcs[i]
+	}
+
+	results, err := r.createOrdersBatch(ctx, orders, srcs, policy)
+	if err == nil {
+		r.wroteSaved(results)
+		return results, nil
+	}
+	if ctx.Err() != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	span.AddEvent("batch failed, falling back to single inserts")
+
+	results = make([]model.SaveResult, len(orders))
+	for i, order := range orders {
+		results[i], err = r.createOrder(ctx, order, policy, source(i))
+		if err != nil {
+			results[i].Err = fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	r.wroteSaved(results)
+	return results, nil
+}
+
+// wroteSaved marks the orders that were actually written for read-your-writes.
+func (r *Repository) wroteSaved(results []model.SaveResult) {
+	uids := make([]string, 0, len(results))
+	for _, res := range results {
+		if res.Err == nil && res.Status.Changed() {
+			uids = append(uids, res.OrderUID)
+		}
+	}
+	r.wrote(uids...)
+}
+
+// createOrdersBatch saves the orders and their ledger entries in one
+// transaction, so a crash cannot leave an order without its ledger entry or
+// the other way round.
+func (r *Repository) createOrdersBatch(ctx context.Context, orders []*model.Order, srcs []model.MessageSource, policy model.ConflictPolicy) ([]model.SaveResult, error) {
+	if err := r.ensurePartitions(ctx, orders...); err != nil {
+		return nil, err
+	}
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	batch := &pgx.Batch{}
+	hashes := make([]string, len(orders))
+	for i, order := range orders {
+		hash, err := order.ContentHash()
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+		batch.Queue(qInsertOrder, append(orderArgs(order), hash)...)
+	}
+
+	results := make([]model.SaveResult, len(orders))
+	inserted := make([]*model.Order, 0, len(orders))
+	var insertedSrcs []*model.MessageSource
+	var existing []int
+
+	br := tx.SendBatch(ctx, batch)
+	for i, order := range orders {
+		t, err := br.Exec()
+		if err != nil {
+			_ = br.Close()
+			return nil, err
+		}
+		if t.RowsAffected() == 0 {
+			existing = append(existing, i)
+			continue
+		}
+		results[i] = model.SaveResult{OrderUID: order.OrderUID, Status: model.SaveInserted}
+		inserted = append(inserted, order)
+		if srcs != nil {
+			insertedSrcs = append(insertedSrcs, &srcs[i])
+		}
+	}
+	if err := br.Close(); err != nil {
+		return nil, err
+	}
+
+	if err := insertDetailsBatch(ctx, tx, inserted, insertedSrcs); err != nil {
+		return nil, err
+	}
+
+	// Existing orders are resolved after the new ones are complete, since a
+	// batch may carry the same order_uid twice.
+	for _, i := range existing {
+		res, err := r.resolveConflict(ctx, tx, orders[i], hashes[i], policy)
+		if err != nil {
+			return nil, err
+		}
+		if srcs != nil {
+			if err := recordIngestion(ctx, tx, orders[i], &srcs[i], res.Status); err != nil {
+				return nil, err
+			}
+		}
+		results[i] = res
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	for _, order := range inserted {
+		order.Version = 1
+	}
+	return results, nil
+}
+
+// insertDetailsBatch writes everything that belongs to the newly inserted
+// orders besides the order row.
+func insertDetailsBatch(ctx context.Context, tx pgx.Tx, inserted []*model.Order, insertedSrcs []*model.MessageSource) error {
+	if len(inserted) == 0 {
+		return nil
+	}
+
+	batch := &pgx.Batch{}
+	var items [][]any
+	for i, order := range inserted {
+		batch.Queue(qInsertDelivery, deliveryArgs(order)...)
+		batch.Queue(qInsertPayment, paymentArgs(order)...)
+		batch.Queue(qInsertStatusHistory, order.OrderUID, "", orderStatus(order), "")
+		event, err := orderCreatedArgs(order)
+		if err != nil {
+			return err
+		}
+		batch.Queue(qInsertOutbox, event...)
+		if insertedSrcs != nil {
+			src := insertedSrcs[i]
+			batch.Queue(qRecordIngestion, src.Topic, src.Partition, src.Offset, order.OrderUID,
+				src.PayloadHash, model.SaveInserted, false)
+		}
+		for _, item := range order.Items {
+			items = append(items, itemArgs(order, item))
+		}
+	}
+	if err := tx.SendBatch(ctx, batch).Close(); err != nil {
+		return err
+	}
+
+	_, err := tx.CopyFrom(ctx, pgx.Identifier{"items"}, itemColumns, pgx.CopyFromRows(items))
+	return err
+}
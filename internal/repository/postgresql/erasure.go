@@ -0,0 +1,141 @@
+package postgresql
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	// The order_keys row stays behind as a tombstone, see qMarkErased.
+	qDeleteOrder       = `DELETE FROM orders WHERE order_uid = $1`
+	qDeleteHistory     = `DELETE FROM order_status_history WHERE order_uid = $1`
+	qDeleteTransaction = `DELETE FROM transaction_keys WHERE order_uid = $1`
+	qMarkErased        = `UPDATE order_keys SET erased_at = now() WHERE order_uid = $1`
+	qAnonymizeDelivery = `
+		UPDATE delivery SET name = '', phone = '', zip = '', city = '', address = '', region = '', email = ''
+			WHERE order_uid = $1
+	`
+	// customer_id ties the order to a person and status change reasons are
+	// free text that may mention the recipient, so both are cleared too.
+	// The content hash is cleared so that the original payload no longer
+	// matches the stored order.
+	qAnonymizeOrder   = `UPDATE orders SET customer_id = '', content_hash = '' WHERE order_uid = $1`
+	qAnonymizeHistory = `UPDATE order_status_history SET reason = '' WHERE order_uid = $1`
+	// Outbox payloads carry a copy of the order, so its personal data is
+	// removed as well.
+	qAnonymizeOutbox = `
+		UPDATE outbox SET payload = payload #- '{order,delivery}' #- '{order,customer_id}'
+			WHERE aggregate_id = $1 AND event_type = $2
+	`
+	qDeleteOutbox     = `DELETE FROM outbox WHERE aggregate_id = $1 AND event_type = $2`
+	qDeleteArchive    = `DELETE FROM orders_archive WHERE order_uid = $1`
+	qAnonymizeArchive = `
+		UPDATE orders_archive SET data = data - 'delivery' - 'customer_id', content_hash = ''
+			WHERE order_uid = $1
+	`
+	qInsertErasure = `
+		INSERT INTO order_erasures (order_uid, mode, requested_by, reason)
+			VALUES ($1, $2, $3, $4)
+		RETURNING id, erased_at
+	`
+)
+
+// EraseOrder deletes or anonymizes the order and stores the audit record in
+// the same transaction. The order_uid is kept as a tombstone, so the order
+// cannot be saved again. It returns model.ErrNotFound for an unknown order.
+func (r *Repository) EraseOrder(ctx context.Context, e *model.Erasure) error {
+	const op = "postgresql.EraseOrder"
+
+	ctx, span := r.tr.Start(ctx, "db.erase.orders")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("order_uid", e.OrderUID),
+		attribute.String("mode", string(e.Mode)),
+	)
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	qErase, qArchive, qOutbox := qAnonymizeDelivery, qAnonymizeArchive, qAnonymizeOutbox
+	if e.Mode == model.ErasureDelete {
+		qErase, qArchive, qOutbox = qDeleteOrder, qDeleteArchive, qDeleteOutbox
+	}
+
+	t, err := tx.Exec(ctx, qErase, e.OrderUID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	archived, err := tx.Exec(ctx, qArchive, e.OrderUID)
+	if err != nil {
+		return fmt.Errorf("%s: archive: %w", op, err)
+	}
+	if t.RowsAffected() == 0 && archived.RowsAffected() == 0 {
+		return model.ErrNotFound
+	}
+
+	queries := []string{qAnonymizeOrder, qAnonymizeHistory, qMarkErased}
+	if e.Mode == model.ErasureDelete {
+		queries = []string{qDeleteHistory, qDeleteTransaction, qMarkErased}
+	}
+	for _, q := range queries {
+		if _, err := tx.Exec(ctx, q, e.OrderUID); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, qOutbox, e.OrderUID, model.EventOrderCreated); err != nil {
+		return fmt.Errorf("%s: outbox: %w", op, err)
+	}
+
+	err = tx.QueryRow(ctx, qInsertErasure, e.OrderUID, e.Mode, e.RequestedBy, e.Reason).Scan(&e.ID, &e.ErasedAt)
+	if err != nil {
+		return fmt.Errorf("%s: audit: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	r.wrote(e.OrderUID)
+	return nil
+}
+
+func (r *Repository) ListErasures(ctx context.Context, orderUID string) ([]model.Erasure, error) {
+	const op = "postgresql.ListErasures"
+
+	ctx, span := r.tr.Start(ctx, "db.select.order_erasures")
+	defer span.End()
+
+	q := `
+		SELECT id, order_uid, mode, requested_by, reason, erased_at
+		FROM order_erasures WHERE order_uid = $1 ORDER BY id
+	`
+	rows, err := r.pool.Query(ctx, q, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	erasures := make([]model.Erasure, 0)
+	for rows.Next() {
+		var e model.Erasure
+		if err := rows.Scan(&e.ID, &e.OrderUID, &e.Mode, &e.RequestedBy, &e.Reason, &e.ErasedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		erasures = append(erasures, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return erasures, nil
+}
@@ -0,0 +1,185 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const ledgerColumns = `topic, partition, "offset", order_uid, payload_hash, save_status, conflicting, processed_at`
+
+func scanIngestion(row pgx.Row, rec *model.IngestionRecord) error {
+	return row.Scan(&rec.Topic, &rec.Partition, &rec.Offset, &rec.OrderUID,
+		&rec.PayloadHash, &rec.SaveStatus, &rec.Conflicting, &rec.ProcessedAt)
+}
+
+func (r *Repository) GetIngestion(ctx context.Context, topic string, partition int, offset int64) (*model.IngestionRecord, error) {
+	const op = "postgresql.GetIngestion"
+
+	ctx, span := r.tr.Start(ctx, "db.select.ingestion_ledger")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("topic", topic),
+		attribute.Int("partition", partition),
+		attribute.Int64("offset", offset),
+	)
+
+	q := `SELECT ` + ledgerColumns + ` FROM ingestion_ledger WHERE topic = $1 AND partition = $2 AND "offset" = $3`
+
+	var rec model.IngestionRecord
+	if err := scanIngestion(r.pool.QueryRow(ctx, q, topic, partition, offset), &rec); err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, model.ErrNotFound
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return &rec, nil
+}
+
+// GetIngestions returns the ledger entries of the given messages in one query.
+// Messages that are not in the ledger are left out.
+func (r *Repository) GetIngestions(ctx context.Context, srcs []model.MessageSource) ([]model.IngestionRecord, error) {
+	const op = "postgresql.GetIngestions"
+
+	ctx, span := r.tr.Start(ctx, "db.select.ingestion_ledger.batch")
+	defer span.End()
+	span.SetAttributes(attribute.Int("messages", len(srcs)))
+
+	topics := make([]string, len(srcs))
+	partitions := make([]int32, len(srcs))
+	offsets := make([]int64, len(srcs))
+	for i, src := range srcs {
+		topics[i], partitions[i], offsets[i] = src.Topic, int32(src.Partition), src.Offset
+	}
+
+	q := `SELECT ` + ledgerColumns + ` FROM ingestion_ledger
+		WHERE (topic, partition, "offset") IN (
+			SELECT * FROM unnest($1::text[], $2::int[], $3::bigint[])
+		)`
+	rows, err := r.pool.Query(ctx, q, topics, partitions, offsets)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	records := make([]model.IngestionRecord, 0, len(srcs))
+	for rows.Next() {
+		var rec model.IngestionRecord
+		if err := scanIngestion(rows, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return records, nil
+}
+
+const qRecordIngestion = `
+	INSERT INTO ingestion_ledger (topic, partition, "offset", order_uid, payload_hash, save_status, conflicting)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+	ON CONFLICT (topic, partition, "offset") DO UPDATE SET
+		order_uid = EXCLUDED.order_uid,
+		payload_hash = EXCLUDED.payload_hash,
+		save_status = EXCLUDED.save_status,
+		conflicting = EXCLUDED.conflicting,
+		processed_at = now()
+	RETURNING processed_at
+`
+
+// recordIngestion stores the outcome of processing a message. A message that
+// is processed again overwrites its previous entry.
+func recordIngestion(ctx context.Context, db querier, order *model.Order, src *model.MessageSource, status model.SaveStatus) error {
+	var processedAt time.Time
+	return db.QueryRow(ctx, qRecordIngestion, src.Topic, src.Partition, src.Offset, order.OrderUID,
+		src.PayloadHash, status, status.Conflicting()).Scan(&processedAt)
+}
+
+// IngestOrder stores an order read from Kafka like CreateOrder and records
+// the message in the ingestion ledger in the same transaction.
+func (r *Repository) IngestOrder(ctx context.Context, order *model.Order, src model.MessageSource, policy model.ConflictPolicy) (model.SaveResult, error) {
+	const op = "postgresql.IngestOrder"
+
+	ctx, span := r.tr.Start(ctx, "db.insert.orders.ingest")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("order_uid", order.OrderUID),
+		attribute.Int64("offset", src.Offset),
+	)
+
+	res, err := r.createOrder(ctx, order, policy, &src)
+	if err != nil {
+		res.Status = model.SaveFailed
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		if errors.Is(err, model.ErrVersionConflict) {
+			return res, err
+		}
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+	r.wrote(order.OrderUID)
+	return res, nil
+}
+
+// DeleteIngestions removes up to limit ledger entries processed before the
+// given time and returns how many were removed.
+func (r *Repository) DeleteIngestions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	const op = "postgresql.DeleteIngestions"
+
+	ctx, span := r.tr.Start(ctx, "db.delete.ingestion_ledger")
+	defer span.End()
+
+	q := `
+		DELETE FROM ingestion_ledger
+		WHERE (topic, partition, "offset") IN (
+			SELECT topic, partition, "offset" FROM ingestion_ledger
+			WHERE processed_at < $1
+			LIMIT $2
+		)
+	`
+	t, err := r.pool.Exec(ctx, q, before, limit)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return t.RowsAffected(), nil
+}
+
+func (r *Repository) ListIngestions(ctx context.Context, orderUID string) ([]model.IngestionRecord, error) {
+	const op = "postgresql.ListIngestions"
+
+	ctx, span := r.tr.Start(ctx, "db.select.ingestion_ledger.order")
+	defer span.End()
+
+	q := `SELECT ` + ledgerColumns + ` FROM ingestion_ledger WHERE order_uid = $1 ORDER BY processed_at`
+	rows, err := r.pool.Query(ctx, q, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	records := make([]model.IngestionRecord, 0)
+	for rows.Next() {
+		var rec model.IngestionRecord
+		if err := scanIngestion(rows, &rec); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		records = append(records, rec)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return records, nil
+}
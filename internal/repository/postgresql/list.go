@@ -0,0 +1,246 @@
+package postgresql
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	defaultListLimit = 20
+	maxListLimit     = 100
+)
+
+type cursor struct {
+	Sort  model.OrderSort `json:"s"`
+	Value string          `json:"v"`
+	UID   string          `json:"u"`
+}
+
+func encodeCursor(c cursor) string {
+	data, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(s string, sort model.OrderSort) (cursor, error) {
+	var c cursor
+	data, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return c, model.ErrInvalidCursor
+	}
+	if err := json.Unmarshal(data, &c); err != nil || c.Sort != sort || c.UID == "" {
+		return c, model.ErrInvalidCursor
+	}
+	return c, nil
+}
+
+type listQuery struct {
+	where []string
+	args  []any
+}
+
+func (q *listQuery) add(cond string, args ...any) {
+	for _, arg := range args {
+		q.args = append(q.args, arg)
+		cond = strings.Replace(cond, "?", "$"+strconv.Itoa(len(q.args)), 1)
+	}
+	q.where = append(q.where, cond)
+}
+
+func (r *Repository) ListOrders(ctx context.Context, f model.OrderFilter) (*model.OrderPage, error) {
+	const op = "postgresql.ListOrders"
+
+	ctx, span := r.tr.Start(ctx, "db.select.orders.list")
+	defer span.End()
+
+	if f.Sort == "" {
+		f.Sort = model.SortCreatedDesc
+	}
+	if f.Limit <= 0 {
+		f.Limit = defaultListLimit
+	}
+	f.Limit = min(f.Limit, maxListLimit)
+	span.SetAttributes(attribute.String("sort", string(f.Sort)), attribute.Int("limit", f.Limit))
+
+	sortColumn, desc := "o.date_created", strings.HasPrefix(string(f.Sort), "-")
+	if f.Sort == model.SortAmountAsc || f.Sort == model.SortAmountDesc {
+		sortColumn = "p.amount"
+	}
+
+	var q listQuery
+	if f.CustomerID != "" {
+		q.add("o.customer_id = ?", f.CustomerID)
+	}
+	if f.TrackNumber != "" {
+		q.add("o.track_number = ?", f.TrackNumber)
+	}
+	if f.DeliveryService != "" {
+		q.add("o.delivery_service = ?", f.DeliveryService)
+	}
+	if !f.CreatedFrom.IsZero() {
+		q.add("o.date_created >= ?", f.CreatedFrom)
+	}
+	if !f.CreatedTo.IsZero() {
+		q.add("o.date_created < ?", f.CreatedTo)
+	}
+	if f.PaymentProvider != "" {
+		q.add("p.provider = ?", f.PaymentProvider)
+	}
+	if f.PaymentBank != "" {
+		q.add("p.bank = ?", f.PaymentBank)
+	}
+	if f.ItemBrand != "" {
+		q.add("EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created AND i.brand = ?)", f.ItemBrand)
+	}
+	if f.ItemNmID != 0 {
+		q.add("EXISTS (SELECT 1 FROM items i WHERE i.order_uid = o.order_uid AND i.date_created = o.date_created AND i.nm_id = ?)", f.ItemNmID)
+	}
+
+	if f.Cursor != "" {
+		c, err := decodeCursor(f.Cursor, f.Sort)
+		if err != nil {
+			return nil, err
+		}
+		value, err := cursorValue(f.Sort, c.Value)
+		if err != nil {
+			return nil, err
+		}
+		cmp := ">"
+		if desc {
+			cmp = "<"
+		}
+		q.add(fmt.Sprintf("(%s, o.order_uid) %s (?, ?)", sortColumn, cmp), value, c.UID)
+	}
+
+	direction := "ASC"
+	if desc {
+		direction = "DESC"
+	}
+
+	qOrders := `SELECT ` + orderColumns + orderJoins
+	if len(q.where) > 0 {
+		qOrders += `
+		WHERE ` + strings.Join(q.where, " AND ")
+	}
+	qOrders += fmt.Sprintf(`
+		ORDER BY %s %s, o.order_uid %s
+		LIMIT %d`, sortColumn, direction, direction, f.Limit+1)
+
+	var page *model.OrderPage
+	err := r.read(ctx, "", func(db querier) error {
+		var err error
+		page, err = listOrders(ctx, db, qOrders, q.args, f, sortColumn)
+		return err
+	})
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	return page, nil
+}
+
+func listOrders(ctx context.Context, db querier, qOrders string, args []any, f model.OrderFilter, sortColumn string) (*model.OrderPage, error) {
+	rows, err := db.Query(ctx, qOrders, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query orders: %w", err)
+	}
+	defer rows.Close()
+
+	page := &model.OrderPage{Orders: make([]*model.Order, 0, f.Limit)}
+	for rows.Next() {
+		o, err := scanOrder(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan order: %w", err)
+		}
+		page.Orders = append(page.Orders, o)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("rows iteration: %w", err)
+	}
+
+	if len(page.Orders) > f.Limit {
+		page.Orders = page.Orders[:f.Limit]
+		last := page.Orders[len(page.Orders)-1]
+		value := last.DateCreated.Format(time.RFC3339Nano)
+		if sortColumn == "p.amount" {
+			value = strconv.Itoa(last.Payment.Amount)
+		}
+		page.NextCursor = encodeCursor(cursor{Sort: f.Sort, Value: value, UID: last.OrderUID})
+	}
+
+	if err := loadItems(ctx, db, page.Orders); err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func cursorValue(sort model.OrderSort, value string) (any, error) {
+	switch sort {
+	case model.SortAmountAsc, model.SortAmountDesc:
+		amount, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, model.ErrInvalidCursor
+		}
+		return amount, nil
+	default:
+		createdAt, err := time.Parse(time.RFC3339Nano, value)
+		if err != nil {
+			return nil, model.ErrInvalidCursor
+		}
+		return createdAt, nil
+	}
+}
+
+func loadItems(ctx context.Context, db querier, orders []*model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+
+	byUID := make(map[string]*model.Order, len(orders))
+	uids := make([]string, 0, len(orders))
+	dates := make([]time.Time, 0, len(orders))
+	for _, o := range orders {
+		byUID[o.OrderUID] = o
+		uids = append(uids, o.OrderUID)
+		dates = append(dates, o.DateCreated)
+	}
+
+	qItems := `SELECT order_uid, ` + itemColumnsList + `
+		FROM items
+		WHERE (order_uid, date_created) IN (SELECT * FROM unnest($1::text[], $2::timestamptz[]))
+		ORDER BY id
+	`
+	rows, err := db.Query(ctx, qItems, uids, dates)
+	if err != nil {
+		return fmt.Errorf("failed to query items: %w", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			uid string
+			i   model.Item
+		)
+		err := rows.Scan(&uid,
+			&i.ChrtID, &i.TrackNumber, &i.Price, &i.Rid, &i.Name, &i.Sale, &i.Size,
+			&i.TotalPrice, &i.NmID, &i.Brand, &i.Status,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to scan item: %w", err)
+		}
+		if o, ok := byUID[uid]; ok {
+			o.Items = append(o.Items, i)
+		}
+	}
+	return rows.Err()
+}
@@ -0,0 +1,92 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func (r *Repository) GetOrderUIDByTrackNumber(ctx context.Context, trackNumber string) (string, error) {
+	const q = `
+		SELECT order_uid
+		FROM orders
+		WHERE track_number = $1
+		ORDER BY date_created DESC
+		LIMIT 1
+	`
+	const qArchive = `
+		SELECT order_uid
+		FROM orders_archive
+		WHERE data->>'track_number' = $1
+		ORDER BY date_created DESC
+		LIMIT 1
+	`
+	return r.lookupOrderUID(ctx, "postgresql.GetOrderUIDByTrackNumber", q, qArchive, trackNumber)
+}
+
+func (r *Repository) GetOrderUIDByTransaction(ctx context.Context, transaction string) (string, error) {
+	const q = `
+		SELECT order_uid
+		FROM transaction_keys
+		WHERE transaction = $1
+	`
+	const qArchive = `
+		SELECT order_uid
+		FROM orders_archive
+		WHERE data->'payment'->>'transaction' = $1
+	`
+	return r.lookupOrderUID(ctx, "postgresql.GetOrderUIDByTransaction", q, qArchive, transaction)
+}
+
+func (r *Repository) GetOrderUIDByRid(ctx context.Context, rid string) (string, error) {
+	const q = `
+		SELECT order_uid
+		FROM items
+		WHERE rid = $1
+		ORDER BY id DESC
+		LIMIT 1
+	`
+	const qArchive = `
+		SELECT order_uid
+		FROM orders_archive
+		WHERE data->'items' @> jsonb_build_array(jsonb_build_object('rid', $1::text))
+		ORDER BY date_created DESC
+		LIMIT 1
+	`
+	return r.lookupOrderUID(ctx, "postgresql.GetOrderUIDByRid", q, qArchive, rid)
+}
+
+// lookupOrderUID runs query against the live orders and, if nothing matches,
+// archiveQuery against orders_archive.
+func (r *Repository) lookupOrderUID(ctx context.Context, op, query, archiveQuery, value string) (string, error) {
+	ctx, span := r.tr.Start(ctx, "db.select.order_uid")
+	defer span.End()
+
+	var uid string
+	err := r.read(ctx, "", func(db querier) error {
+		err := db.QueryRow(ctx, query, value).Scan(&uid)
+		if errors.Is(err, pgx.ErrNoRows) {
+			err = db.QueryRow(ctx, archiveQuery, value).Scan(&uid)
+		}
+		if err != nil {
+			if errors.Is(err, pgx.ErrNoRows) {
+				return model.ErrNotFound
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return "", model.ErrNotFound
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return uid, nil
+}
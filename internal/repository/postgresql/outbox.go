@@ -0,0 +1,139 @@
+package postgresql
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const qInsertOutbox = `
+	INSERT INTO outbox (event_type, aggregate_id, payload)
+		VALUES ($1, $2, $3)
+`
+
+func orderCreatedArgs(order *model.Order) ([]any, error) {
+	payload, err := json.Marshal(model.OrderEvent{
+		Type:       model.EventOrderCreated,
+		OrderUID:   order.OrderUID,
+		Version:    1,
+		OccurredAt: time.Now().UTC(),
+		Order:      order,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []any{model.EventOrderCreated, order.OrderUID, payload}, nil
+}
+
+func insertOrderCreated(ctx context.Context, tx pgx.Tx, order *model.Order) error {
+	args, err := orderCreatedArgs(order)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, qInsertOutbox, args...)
+	return err
+}
+
+// ClaimOutbox locks up to limit pending messages for lease. Messages that are
+// neither published nor released before the lease expires are handed out
+// again, which gives at-least-once delivery if the relay crashes.
+func (r *Repository) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	const op = "postgresql.ClaimOutbox"
+
+	ctx, span := r.tr.Start(ctx, "db.update.outbox.claim")
+	defer span.End()
+
+	q := `
+		UPDATE outbox SET
+			available_at = now() + make_interval(secs => $2),
+			attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox
+			WHERE published_at IS NULL AND available_at <= now()
+			ORDER BY id
+			LIMIT $1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_id, payload, attempts, created_at
+	`
+	rows, err := r.pool.Query(ctx, q, limit, lease.Seconds())
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	var msgs []model.OutboxMessage
+	for rows.Next() {
+		var m model.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.EventType, &m.Key, &m.Payload, &m.Attempts, &m.CreatedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	slices.SortFunc(msgs, func(a, b model.OutboxMessage) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	span.SetAttributes(attribute.Int("messages", len(msgs)))
+	return msgs, nil
+}
+
+func (r *Repository) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	const op = "postgresql.MarkOutboxPublished"
+
+	ctx, span := r.tr.Start(ctx, "db.update.outbox.published")
+	defer span.End()
+	span.SetAttributes(attribute.Int("messages", len(ids)))
+
+	_, err := r.pool.Exec(ctx, `UPDATE outbox SET published_at = now(), last_error = NULL WHERE id = ANY($1)`, ids)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// MarkOutboxFailed releases the message so that it is retried after delay.
+func (r *Repository) MarkOutboxFailed(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	const op = "postgresql.MarkOutboxFailed"
+
+	ctx, span := r.tr.Start(ctx, "db.update.outbox.failed")
+	defer span.End()
+
+	q := `UPDATE outbox SET available_at = now() + make_interval(secs => $2), last_error = $3 WHERE id = $1`
+	if _, err := r.pool.Exec(ctx, q, id, delay.Seconds(), reason); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+func (r *Repository) DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error) {
+	const op = "postgresql.DeletePublishedOutbox"
+
+	ctx, span := r.tr.Start(ctx, "db.delete.outbox")
+	defer span.End()
+
+	t, err := r.pool.Exec(ctx, `DELETE FROM outbox WHERE published_at < $1`, before)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return 0, fmt.Errorf("%s: %w", op, err)
+	}
+	return t.RowsAffected(), nil
+}
@@ -0,0 +1,164 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// partitionedTables are partitioned by date_created in monthly ranges. Details
+// come before orders so that they are detached first.
+var partitionedTables = []string{"items", "payment", "delivery", "orders"}
+
+const partitionMonthLayout = "200601"
+
+var errPartitionNotArchived = errors.New("partition still holds orders that are not archived")
+
+func monthStart(t time.Time) time.Time {
+	t = t.UTC()
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func partitionName(table string, month time.Time) string {
+	return table + "_p" + month.Format(partitionMonthLayout)
+}
+
+// CreatePartitions makes sure each of the order tables has a partition for
+// every month between from and to.
+func (r *Repository) CreatePartitions(ctx context.Context, from, to time.Time) error {
+	const op = "postgresql.CreatePartitions"
+
+	ctx, span := r.tr.Start(ctx, "db.partitions.create")
+	defer span.End()
+
+	for m := monthStart(from); !m.After(to); m = m.AddDate(0, 1, 0) {
+		if err := r.createMonth(ctx, m); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+	}
+	return nil
+}
+
+// ensurePartitions creates the partitions of the months the orders fall into.
+// There is no default partition, so an order outside of them cannot be
+// inserted.
+func (r *Repository) ensurePartitions(ctx context.Context, orders ...*model.Order) error {
+	for _, order := range orders {
+		m := monthStart(order.DateCreated)
+		if _, ok := r.months.Load(m); ok {
+			continue
+		}
+		if err := r.createMonth(ctx, m); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *Repository) createMonth(ctx context.Context, month time.Time) error {
+	for _, table := range partitionedTables {
+		name := partitionName(table, month)
+		q := fmt.Sprintf(`CREATE TABLE IF NOT EXISTS %s PARTITION OF %s FOR VALUES FROM ('%s') TO ('%s')`,
+			pgx.Identifier{name}.Sanitize(), pgx.Identifier{table}.Sanitize(),
+			month.Format(time.RFC3339), month.AddDate(0, 1, 0).Format(time.RFC3339))
+		if _, err := r.pool.Exec(ctx, q); err != nil {
+			// Another instance may have created it between IF NOT EXISTS and the insert
+			// into the catalog.
+			var exists bool
+			if qerr := r.pool.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); qerr != nil || !exists {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	r.months.Store(month, struct{}{})
+	return nil
+}
+
+// DropPartitions removes the monthly partitions that end before the given
+// time and returns the months that were dropped. A month that still holds
+// orders is not dropped; archive them first with ArchiveOrders.
+func (r *Repository) DropPartitions(ctx context.Context, before time.Time) ([]string, error) {
+	const op = "postgresql.DropPartitions"
+
+	ctx, span := r.tr.Start(ctx, "db.partitions.drop")
+	defer span.End()
+
+	q := `
+		SELECT c.relname
+		FROM pg_inherits i
+		JOIN pg_class c ON c.oid = i.inhrelid
+		WHERE i.inhparent = 'orders'::regclass
+		ORDER BY c.relname
+	`
+	rows, err := r.pool.Query(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	names, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	var dropped []string
+	for _, name := range names {
+		month, err := time.Parse(partitionMonthLayout, strings.TrimPrefix(name, "orders_p"))
+		if err != nil || month.AddDate(0, 1, 0).After(before) {
+			continue
+		}
+		if err := r.dropMonth(ctx, month); err != nil {
+			return dropped, fmt.Errorf("%s: %s: %w", op, name, err)
+		}
+		r.months.Delete(month)
+		dropped = append(dropped, month.Format(partitionMonthLayout))
+	}
+	span.SetAttributes(attribute.Int("partitions", len(dropped)))
+	return dropped, nil
+}
+
+func (r *Repository) dropMonth(ctx context.Context, month time.Time) error {
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	orders := pgx.Identifier{partitionName("orders", month)}.Sanitize()
+	// Block inserts into the month until it is detached.
+	if _, err := tx.Exec(ctx, `LOCK TABLE `+orders+` IN ACCESS EXCLUSIVE MODE`); err != nil {
+		return err
+	}
+	var pending bool
+	if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM `+orders+`)`).Scan(&pending); err != nil {
+		return err
+	}
+	if pending {
+		return errPartitionNotArchived
+	}
+
+	for _, table := range partitionedTables {
+		name := partitionName(table, month)
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT to_regclass($1) IS NOT NULL`, name).Scan(&exists); err != nil {
+			return err
+		}
+		if !exists {
+			continue
+		}
+		part := pgx.Identifier{name}.Sanitize()
+		if _, err := tx.Exec(ctx, `ALTER TABLE `+pgx.Identifier{table}.Sanitize()+` DETACH PARTITION `+part); err != nil {
+			return err
+		}
+		if _, err := tx.Exec(ctx, `DROP TABLE `+part); err != nil {
+			return err
+		}
+	}
+	return tx.Commit(ctx)
+}
@@ -4,178 +4,357 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/MikebangSfilya/wb/internal/model"
 	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
 )
 
 type Repository struct {
-	pool *pgxpool.Pool
-	tr   trace.Tracer
+	pool     *pgxpool.Pool
+	replicas []*replica
+	next     atomic.Uint64
+	maxLag   time.Duration
+	writes   writeLog
+	readMode ReadMode
+	// months holds the months whose partitions are known to exist.
+	months sync.Map
+	tr     trace.Tracer
 }
 
-func New(pool *pgxpool.Pool, tr trace.Tracer) *Repository {
-	return &Repository{
-		pool: pool,
-		tr:   tr,
+func New(pool *pgxpool.Pool, tr trace.Tracer, opts ...Option) *Repository {
+	r := &Repository{
+		pool:     pool,
+		readMode: ReadJoins,
+		tr:       tr,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
 }
 
-func (r *Repository) CreateOrder(ctx context.Context, order *model.Order) error {
+const (
+	// order_keys keeps order_uid unique across the date_created partitions of
+	// orders; an order row is only inserted when its key is new.
+	qInsertOrder = `
+		WITH k AS (
+			INSERT INTO order_keys (order_uid) VALUES ($1)
+			ON CONFLICT (order_uid) DO NOTHING
+			RETURNING order_uid
+		)
+		INSERT INTO orders (
+			order_uid, track_number, entry, locale, internal_signature,
+			customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard, status,
+			content_hash)
+		SELECT k.order_uid, $2::text, $3::text, $4::text, $5::text, $6::text, $7::text, $8::text,
+			$9::integer, $10::timestamptz, $11::text, $12::text, $13::text
+		FROM k
+	`
+	qInsertDelivery = `
+		INSERT INTO delivery (
+			order_uid, name, phone, zip, city, address, region, email, date_created) 
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+	// transaction_keys keeps the payment transaction unique across partitions;
+	// a transaction of another order fails the insert.
+	qInsertPayment = `
+		WITH k AS (
+			INSERT INTO transaction_keys (transaction, order_uid) VALUES ($1, $2)
+		)
+		INSERT INTO payment (
+			transaction, order_uid, request_id, currency, provider, amount, 
+			payment_dt, bank, delivery_cost, goods_total, custom_fee, date_created)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+	qInsertItem = `
+		INSERT INTO items (
+			order_uid, chrt_id, track_number, price, rid, name, sale, 
+			size, total_price, nm_id, brand, status, date_created)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+	`
+	qInsertStatusHistory = `
+		INSERT INTO order_status_history (order_uid, from_status, to_status, reason)
+			VALUES ($1, NULLIF($2, ''), $3, $4)
+	`
+)
+
+// CreateOrder stores the order. When an order with the same order_uid already
+// exists, the outcome is decided by policy and reported in the returned result.
+func (r *Repository) CreateOrder(ctx context.Context, order *model.Order, policy model.ConflictPolicy) (model.SaveResult, error) {
 	const op = "postgresql.CreateOrder"
 
+	res, err := r.createOrder(ctx, order, policy, nil)
+	if err != nil {
+		res.Status = model.SaveFailed
+		if errors.Is(err, model.ErrVersionConflict) {
+			return res, err
+		}
+		return res, fmt.Errorf("%s: %w", op, err)
+	}
+	r.wrote(order.OrderUID)
+	return res, nil
+}
+
+// createOrder saves the order in one transaction. When src is set, the
+// message is recorded in the ingestion ledger in the same transaction.
+func (r *Repository) createOrder(ctx context.Context, order *model.Order, policy model.ConflictPolicy, src *model.MessageSource) (model.SaveResult, error) {
+	res := model.SaveResult{OrderUID: order.OrderUID, Status: model.SaveFailed}
+
+	hash, err := order.ContentHash()
+	if err != nil {
+		return res, err
+	}
+
+	if err := r.ensurePartitions(ctx, order); err != nil {
+		return res, err
+	}
+
 	tx, err := r.pool.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return res, err
 	}
 	defer func() {
 		_ = tx.Rollback(ctx)
 	}()
 
-	qOrder := `
-       INSERT INTO orders (
-               order_uid, track_number, entry, locale, internal_signature, 
-               customer_id, delivery_service, shardkey, sm_id, date_created, oof_shard) 
-			   VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-       ON CONFLICT (order_uid) DO NOTHING
-       `
-	t, err := tx.Exec(ctx, qOrder, order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
-		order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard)
+	t, err := tx.Exec(ctx, qInsertOrder, append(orderArgs(order), hash)...)
 	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+		return res, err
 	}
-	if t.RowsAffected() == 0 {
-		return nil
+	if t.RowsAffected() > 0 {
+		if err := insertOrderDetails(ctx, tx, order); err != nil {
+			return res, err
+		}
+		_, err = tx.Exec(ctx, qInsertStatusHistory, order.OrderUID, "", orderStatus(order), "")
+		if err != nil {
+			return res, err
+		}
+		if err := insertOrderCreated(ctx, tx, order); err != nil {
+			return res, err
+		}
+		order.Version = 1
+		res.Status = model.SaveInserted
+	} else {
+		res, err = r.resolveConflict(ctx, tx, order, hash, policy)
+		if err != nil {
+			return res, err
+		}
+		if res.Status != model.SaveUpdated && src == nil {
+			return res, nil
+		}
 	}
 
-	qDelivery := `
-		INSERT INTO delivery (
-			order_uid, name, phone, zip, city, address, region, email) 
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
-	`
-	_, err = tx.Exec(ctx, qDelivery,
-		order.OrderUID,
-		order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
-		order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
-	)
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if src != nil {
+		if err := recordIngestion(ctx, tx, order, src, res.Status); err != nil {
+			res.Status = model.SaveFailed
+			return res, err
+		}
 	}
 
-	qPayment := `
-		INSERT INTO payment (
-			transaction, order_uid, request_id, currency, provider, amount, 
-			payment_dt, bank, delivery_cost, goods_total, custom_fee)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
-	`
-	_, err = tx.Exec(ctx, qPayment,
-		order.Payment.Transaction, order.OrderUID, order.Payment.RequestID, order.Payment.Currency,
-		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank,
-		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
-	)
-
-	if err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+	if err := tx.Commit(ctx); err != nil {
+		res.Status = model.SaveFailed
+		return res, err
 	}
+	return res, nil
+}
 
-	qItems := `
-		INSERT INTO items (
-			order_uid, chrt_id, track_number, price, rid, name, sale, 
-			size, total_price, nm_id, brand, status)
-			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
-	`
+func insertOrderDetails(ctx context.Context, tx pgx.Tx, order *model.Order) error {
+	if _, err := tx.Exec(ctx, qInsertDelivery, deliveryArgs(order)...); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(ctx, qInsertPayment, paymentArgs(order)...); err != nil {
+		return transactionConflict(order, err)
+	}
 	for _, item := range order.Items {
-		_, err := tx.Exec(ctx, qItems,
-			order.OrderUID,
-			item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale,
-			item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
-		)
-		if err != nil {
-			return fmt.Errorf("%s: %w", op, err)
+		if _, err := tx.Exec(ctx, qInsertItem, itemArgs(order, item)...); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	if err := tx.Commit(ctx); err != nil {
-		return fmt.Errorf("%s: %w", op, err)
+// transactionConflict reports a payment transaction that belongs to another
+// order as model.ErrOrderConflict.
+func transactionConflict(order *model.Order, err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.TableName == "transaction_keys" {
+		return fmt.Errorf("%w: payment transaction %s belongs to another order", model.ErrOrderConflict, order.Payment.Transaction)
 	}
+	return err
+}
 
-	return nil
+func orderStatus(order *model.Order) model.OrderStatus {
+	if order.Status == "" {
+		return model.StatusCreated
+	}
+	return order.Status
 }
 
-func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
-	const op = "postgresql.GetOrder"
+func orderArgs(order *model.Order) []any {
+	return []any{
+		order.OrderUID, order.TrackNumber, order.Entry, order.Locale, order.InternalSignature,
+		order.CustomerID, order.DeliveryService, order.Shardkey, order.SmID, order.DateCreated, order.OofShard,
+		orderStatus(order),
+	}
+}
 
-	ctx, span := r.tr.Start(ctx, "db.select.orders")
-	defer span.End()
+func deliveryArgs(order *model.Order) []any {
+	return []any{
+		order.OrderUID,
+		order.Delivery.Name, order.Delivery.Phone, order.Delivery.Zip, order.Delivery.City,
+		order.Delivery.Address, order.Delivery.Region, order.Delivery.Email,
+		order.DateCreated,
+	}
+}
+
+func paymentArgs(order *model.Order) []any {
+	return []any{
+		order.Payment.Transaction, order.OrderUID, order.Payment.RequestID, order.Payment.Currency,
+		order.Payment.Provider, order.Payment.Amount, order.Payment.PaymentDt, order.Payment.Bank,
+		order.Payment.DeliveryCost, order.Payment.GoodsTotal, order.Payment.CustomFee,
+		order.DateCreated,
+	}
+}
+
+func itemArgs(order *model.Order, item model.Item) []any {
+	return []any{
+		order.OrderUID,
+		item.ChrtID, item.TrackNumber, item.Price, item.Rid, item.Name, item.Sale,
+		item.Size, item.TotalPrice, item.NmID, item.Brand, item.Status,
+		order.DateCreated,
+	}
+}
 
-	qOrder := `
-		SELECT 
+const (
+	orderColumns = `
 			o.order_uid, o.track_number, o.entry, o.locale, o.internal_signature, 
-			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard,
+			o.customer_id, o.delivery_service, o.shardkey, o.sm_id, o.date_created, o.oof_shard, o.status, o.version,
 			d.name, d.phone, d.zip, d.city, d.address, d.region, d.email,
 			p.transaction, p.request_id, p.currency, p.provider, p.amount, p.payment_dt, 
-			p.bank, p.delivery_cost, p.goods_total, p.custom_fee
+			p.bank, p.delivery_cost, p.goods_total, p.custom_fee`
+	// The details are joined on the partition key as well, so that only the
+	// order's partition is scanned.
+	orderJoins = `
 		FROM orders o
-		JOIN delivery d ON o.order_uid = d.order_uid
-		JOIN payment p ON o.order_uid = p.order_uid
-		WHERE o.order_uid = $1
-	`
+		JOIN delivery d ON o.order_uid = d.order_uid AND o.date_created = d.date_created
+		JOIN payment p ON o.order_uid = p.order_uid AND o.date_created = p.date_created`
+	itemColumnsList = `
+			chrt_id, track_number, price, rid, name, sale, size, 
+			total_price, nm_id, brand, status`
+)
 
+func scanOrder(row pgx.Row) (*model.Order, error) {
 	var o model.Order
-
-	err := r.pool.QueryRow(ctx, qOrder, orderUID).Scan(
+	err := row.Scan(
 		&o.OrderUID, &o.TrackNumber, &o.Entry, &o.Locale, &o.InternalSignature,
-		&o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated, &o.OofShard,
+		&o.CustomerID, &o.DeliveryService, &o.Shardkey, &o.SmID, &o.DateCreated, &o.OofShard, &o.Status, &o.Version,
 		&o.Delivery.Name, &o.Delivery.Phone, &o.Delivery.Zip, &o.Delivery.City,
 		&o.Delivery.Address, &o.Delivery.Region, &o.Delivery.Email,
 		&o.Payment.Transaction, &o.Payment.RequestID, &o.Payment.Currency, &o.Payment.Provider,
 		&o.Payment.Amount, &o.Payment.PaymentDt, &o.Payment.Bank, &o.Payment.DeliveryCost,
 		&o.Payment.GoodsTotal, &o.Payment.CustomFee,
 	)
+	if err != nil {
+		return nil, err
+	}
+	o.Items = make([]model.Item, 0)
+	return &o, nil
+}
+
+func scanItem(row pgx.Row, i *model.Item) error {
+	return row.Scan(
+		&i.ChrtID, &i.TrackNumber, &i.Price, &i.Rid, &i.Name, &i.Sale, &i.Size,
+		&i.TotalPrice, &i.NmID, &i.Brand, &i.Status,
+	)
+}
+
+func (r *Repository) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	const op = "postgresql.GetOrder"
 
+	ctx, span := r.tr.Start(ctx, "db.select.orders")
+	defer span.End()
+
+	var o *model.Order
+	err := r.read(ctx, orderUID, func(db querier) error {
+		var err error
+		o, err = r.getOrder(ctx, db, orderUID)
+		return err
+	})
 	if err != nil {
-		if errors.Is(err, pgx.ErrNoRows) {
+		if errors.Is(err, model.ErrNotFound) {
 			return nil, model.ErrNotFound
 		}
 		span.RecordError(err)
 		span.SetStatus(codes.Error, "msg")
-		return nil, fmt.Errorf("%s: failed to query o: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
 	}
+	return o, nil
+}
 
-	o.Items = make([]model.Item, 0)
+func (r *Repository) getOrder(ctx context.Context, db querier, orderUID string) (*model.Order, error) {
+	var (
+		o   *model.Order
+		err error
+	)
+	if r.readMode == ReadJSON {
+		o, err = getOrderJSON(ctx, db, orderUID)
+	} else {
+		o, err = getOrderJoined(ctx, db, orderUID)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		archived, err := r.getArchivedOrder(ctx, db, orderUID)
+		if err != nil && !errors.Is(err, model.ErrNotFound) {
+			return nil, fmt.Errorf("failed to query archive: %w", err)
+		}
+		return archived, err
+	}
+	return o, err
+}
 
-	qItems := `
-		SELECT 
-			chrt_id, track_number, price, rid, name, sale, size, 
-			total_price, nm_id, brand, status
+// getOrderJoined reads the order header and its items with two queries.
+func getOrderJoined(ctx context.Context, db querier, orderUID string) (*model.Order, error) {
+	qOrder := `SELECT ` + orderColumns + orderJoins + `
+		WHERE o.order_uid = $1
+	`
+
+	o, err := scanOrder(db.QueryRow(ctx, qOrder, orderUID))
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("failed to query o: %w", err)
+	}
+
+	qItems := `SELECT ` + itemColumnsList + `
 		FROM items
-		WHERE order_uid = $1
+		WHERE order_uid = $1 AND date_created = $2
+		ORDER BY id
 	`
 
-	rows, err := r.pool.Query(ctx, qItems, orderUID)
+	rows, err := db.Query(ctx, qItems, orderUID, o.DateCreated)
 	if err != nil {
-		return nil, fmt.Errorf("%s: failed to query items: %w", op, err)
+		return nil, fmt.Errorf("failed to query items: %w", err)
 	}
 	defer rows.Close()
 
 	for rows.Next() {
 		var i model.Item
-		err := rows.Scan(
-			&i.ChrtID, &i.TrackNumber, &i.Price, &i.Rid, &i.Name, &i.Sale, &i.Size,
-			&i.TotalPrice, &i.NmID, &i.Brand, &i.Status,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("%s: failed to scan item: %w", op, err)
+		if err := scanItem(rows, &i); err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
 		}
 		o.Items = append(o.Items, i)
 	}
 
 	if err := rows.Err(); err != nil {
-		return nil, fmt.Errorf("%s: rows iteration: %w", op, err)
+		return nil, fmt.Errorf("rows iteration: %w", err)
 	}
 
-	return &o, nil
+	return o, nil
 }
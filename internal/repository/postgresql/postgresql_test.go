@@ -2,10 +2,15 @@ package postgresql
 
 import (
 	"context"
+	"encoding/json"
+	"strconv"
 	"testing"
 	"time"
 
 	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -15,7 +20,34 @@ import (
 	"go.opentelemetry.io/otel/trace/noop"
 )
 
-func TestPostgresRepository(t *testing.T) {
+func newTestRepository(tb testing.TB, opts ...Option) *Repository {
+	tb.Helper()
+	ctx := context.Background()
+
+	connStr := startPostgres(tb)
+	migrateUp(tb, connStr)
+
+	pool, err := pgxpool.New(ctx, connStr)
+	require.NoError(tb, err)
+	tb.Cleanup(pool.Close)
+
+	return New(pool, noop.NewTracerProvider().Tracer("test"), opts...)
+}
+
+// migrateUp applies all migrations, so the tests run against the same schema
+// as the service.
+func migrateUp(tb testing.TB, connStr string) {
+	tb.Helper()
+
+	m, err := migrate.New("file://../../../db/migrations", connStr)
+	require.NoError(tb, err)
+	defer func() { _, _ = m.Close() }()
+	require.NoError(tb, m.Up(), "failed to apply migrations")
+}
+
+// startPostgres runs a Postgres container and returns its connection string.
+func startPostgres(tb testing.TB) string {
+	tb.Helper()
 	ctx := context.Background()
 
 	pgContainer, err := postgres.Run(ctx,
@@ -28,29 +60,29 @@ func TestPostgresRepository(t *testing.T) {
 				WithOccurrence(2).
 				WithStartupTimeout(5*time.Second)),
 	)
-	require.NoError(t, err)
+	require.NoError(tb, err)
 
-	defer func() {
+	tb.Cleanup(func() {
 		if err := pgContainer.Terminate(ctx); err != nil {
-			t.Logf("failed to terminate container: %s", err)
+			tb.Logf("failed to terminate container: %s", err)
 		}
-	}()
+	})
 
 	connStr, err := pgContainer.ConnectionString(ctx, "sslmode=disable")
-	require.NoError(t, err)
-
-	pool, err := pgxpool.New(ctx, connStr)
-	require.NoError(t, err)
-	defer pool.Close()
-
-	_, err = pool.Exec(ctx, initSQL)
-	require.NoError(t, err, "failed to init tables")
+	require.NoError(tb, err)
+	return connStr
+}
 
-	repo := New(pool, noop.NewTracerProvider().Tracer("test"))
+func createTestOrder(tb testing.TB, repo *Repository, order *model.Order) {
+	tb.Helper()
+	res, err := repo.CreateOrder(context.Background(), order, model.ConflictIgnore)
+	require.NoError(tb, err)
+	require.Equal(tb, model.SaveInserted, res.Status)
+}
 
-	fixedTime := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC).Truncate(time.Microsecond)
-	order := &model.Order{
-		OrderUID:          "b563feb7b2b84b6test",
+func testOrder(uid string, createdAt time.Time) *model.Order {
+	return &model.Order{
+		OrderUID:          uid,
 		TrackNumber:       "WBILMTESTTRACK",
 		Entry:             "WBIL",
 		Locale:            "en",
@@ -59,8 +91,9 @@ func TestPostgresRepository(t *testing.T) {
 		DeliveryService:   "meest",
 		Shardkey:          "9",
 		SmID:              99,
-		DateCreated:       fixedTime,
+		DateCreated:       createdAt,
 		OofShard:          "1",
+		Status:            model.StatusCreated,
 		Delivery: model.Delivery{
 			Name:    "Test Testov",
 			Phone:   "+9720000000",
@@ -71,7 +104,7 @@ func TestPostgresRepository(t *testing.T) {
 			Email:   "test@gmail.com",
 		},
 		Payment: model.Payment{
-			Transaction:  "b563feb7b2b84b6test",
+			Transaction:  uid,
 			RequestID:    "",
 			Currency:     "USD",
 			Provider:     "wbpay",
@@ -98,8 +131,16 @@ func TestPostgresRepository(t *testing.T) {
 			},
 		},
 	}
+}
 
-	err = repo.CreateOrder(ctx, order)
+func TestPostgresRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	fixedTime := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC).Truncate(time.Microsecond)
+	order := testOrder("b563feb7b2b84b6test", fixedTime)
+
+	_, err := repo.CreateOrder(ctx, order, model.ConflictIgnore)
 	require.NoError(t, err, "failed to create initial order for tests")
 
 	testCases := []struct {
@@ -138,59 +179,662 @@ func TestPostgresRepository(t *testing.T) {
 	}
 }
 
-const initSQL = `
-CREATE TABLE IF NOT EXISTS orders (
-    order_uid TEXT PRIMARY KEY,
-    track_number TEXT NOT NULL,
-    entry TEXT NOT NULL,
-    locale TEXT NOT NULL,
-    internal_signature TEXT,
-    customer_id TEXT NOT NULL,
-    delivery_service TEXT NOT NULL,
-    shardkey TEXT NOT NULL,
-    sm_id INTEGER NOT NULL,
-    date_created TIMESTAMPTZ NOT NULL,
-    oof_shard TEXT NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS delivery (
-    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
-    name TEXT NOT NULL,
-    phone TEXT NOT NULL,
-    zip TEXT NOT NULL,
-    city TEXT NOT NULL,
-    address TEXT NOT NULL,
-    region TEXT NOT NULL,
-    email TEXT NOT NULL
-);
-
-CREATE TABLE IF NOT EXISTS payment (
-    transaction TEXT PRIMARY KEY,
-    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
-    request_id TEXT,
-    currency TEXT NOT NULL,
-    provider TEXT NOT NULL,
-    amount INTEGER NOT NULL CHECK (amount >= 0),
-    payment_dt BIGINT NOT NULL,
-    bank TEXT NOT NULL,
-    delivery_cost INTEGER NOT NULL CHECK (delivery_cost >= 0),
-    goods_total INTEGER NOT NULL CHECK (goods_total >= 0),
-    custom_fee INTEGER NOT NULL CHECK (custom_fee >= 0)
-);
-
-CREATE TABLE IF NOT EXISTS items (
-    id SERIAL PRIMARY KEY,
-    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
-    chrt_id INTEGER NOT NULL,
-    track_number TEXT NOT NULL,
-    price INTEGER NOT NULL CHECK (price >= 0),
-    rid TEXT NOT NULL,
-    name TEXT NOT NULL,
-    sale INTEGER NOT NULL CHECK (sale >= 0),
-    size TEXT NOT NULL,
-    total_price INTEGER NOT NULL CHECK (total_price >= 0),
-    nm_id INTEGER NOT NULL,
-    brand TEXT NOT NULL,
-    status INTEGER NOT NULL
-);
-`
+func TestPostgresRepository_GetOrderReadModes(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 123456000, time.UTC)
+	order := testOrder("read-modes", createdAt)
+	order.InternalSignature = "sig"
+	order.Items = append(order.Items, order.Items[0])
+	order.Items[1].ChrtID = 42
+	order.Items[1].Rid = "second"
+	createTestOrder(t, repo, order)
+
+	empty := testOrder("read-modes-empty", createdAt)
+	empty.Items = nil
+	createTestOrder(t, repo, empty)
+
+	for _, mode := range []ReadMode{ReadJoins, ReadJSON} {
+		t.Run(string(mode), func(t *testing.T) {
+			r := New(repo.pool, repo.tr, WithReadMode(mode))
+
+			got, err := r.GetOrder(ctx, order.OrderUID)
+			require.NoError(t, err)
+			got.DateCreated = got.DateCreated.In(time.UTC)
+			assert.Equal(t, order, got)
+
+			got, err = r.GetOrder(ctx, empty.OrderUID)
+			require.NoError(t, err)
+			assert.Empty(t, got.Items)
+			assert.NotNil(t, got.Items)
+
+			_, err = r.GetOrder(ctx, "missing")
+			assert.ErrorIs(t, err, model.ErrNotFound)
+		})
+	}
+}
+
+func TestPostgresRepository_CreateOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC)
+	existing := testOrder("batch-existing", createdAt)
+	createTestOrder(t, repo, existing)
+
+	t.Run("inserted_and_duplicate", func(t *testing.T) {
+		results, err := repo.CreateOrders(ctx, []*model.Order{
+			testOrder("batch-1", createdAt),
+			existing,
+			testOrder("batch-2", createdAt),
+		}, model.ConflictIgnore)
+		require.NoError(t, err)
+		require.Len(t, results, 3)
+		assert.Equal(t, model.SaveInserted, results[0].Status)
+		assert.Equal(t, model.SaveDuplicate, results[1].Status)
+		assert.Equal(t, model.SaveInserted, results[2].Status)
+
+		got, err := repo.GetOrder(ctx, "batch-2")
+		require.NoError(t, err)
+		assert.Len(t, got.Items, 1)
+	})
+
+	t.Run("failed_order_is_isolated", func(t *testing.T) {
+		broken := testOrder("batch-broken", createdAt)
+		broken.Payment.Transaction = existing.Payment.Transaction
+
+		results, err := repo.CreateOrders(ctx, []*model.Order{
+			testOrder("batch-3", createdAt),
+			broken,
+		}, model.ConflictIgnore)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, model.SaveInserted, results[0].Status)
+		assert.Equal(t, model.SaveFailed, results[1].Status)
+		assert.Error(t, results[1].Err)
+
+		_, err = repo.GetOrder(ctx, "batch-broken")
+		assert.ErrorIs(t, err, model.ErrNotFound)
+	})
+
+	t.Run("transaction_unique_across_partitions", func(t *testing.T) {
+		other := testOrder("batch-other-month", createdAt.AddDate(0, 3, 0))
+		other.Payment.Transaction = existing.Payment.Transaction
+
+		res, err := repo.CreateOrder(ctx, other, model.ConflictIgnore)
+		assert.ErrorIs(t, err, model.ErrOrderConflict)
+		assert.Equal(t, model.SaveFailed, res.Status)
+
+		uid, err := repo.GetOrderUIDByTransaction(ctx, existing.Payment.Transaction)
+		require.NoError(t, err)
+		assert.Equal(t, existing.OrderUID, uid)
+	})
+}
+
+func TestPostgresRepository_IngestOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC)
+	createTestOrder(t, repo, testOrder("ingest-1", createdAt))
+
+	resent := testOrder("ingest-1", createdAt)
+	resent.Delivery.City = "Tel Aviv"
+	orders := []*model.Order{resent, testOrder("ingest-2", createdAt), testOrder("ingest-3", createdAt)}
+	srcs := []model.MessageSource{
+		{Topic: "orders", Offset: 1, PayloadHash: "h1"},
+		{Topic: "orders", Offset: 2, PayloadHash: "h2"},
+		{Topic: "orders", Offset: 3, PayloadHash: "h3"},
+	}
+
+	results, err := repo.IngestOrders(ctx, orders, srcs, model.ConflictIgnore)
+	require.NoError(t, err)
+	require.Len(t, results, 3)
+	assert.Equal(t, model.SaveIgnored, results[0].Status)
+	assert.Equal(t, model.SaveInserted, results[1].Status)
+	assert.Equal(t, model.SaveInserted, results[2].Status)
+
+	for i, want := range []model.SaveStatus{model.SaveIgnored, model.SaveInserted, model.SaveInserted} {
+		rec, err := repo.GetIngestion(ctx, "orders", 0, srcs[i].Offset)
+		require.NoError(t, err)
+		assert.Equal(t, orders[i].OrderUID, rec.OrderUID)
+		assert.Equal(t, want, rec.SaveStatus)
+		assert.Equal(t, want == model.SaveIgnored, rec.Conflicting)
+	}
+
+	records, err := repo.GetIngestions(ctx, append(srcs, model.MessageSource{Topic: "orders", Offset: 4}))
+	require.NoError(t, err)
+	assert.Len(t, records, 3)
+}
+
+func TestPostgresRepository_CreateOrderConflictPolicy(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC)
+	createTestOrder(t, repo, testOrder("conflict-1", createdAt))
+
+	resent := func() *model.Order {
+		order := testOrder("conflict-1", createdAt)
+		order.Delivery.City = "Tel Aviv"
+		return order
+	}
+
+	res, err := repo.CreateOrder(ctx, testOrder("conflict-1", createdAt), model.ConflictReject)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveDuplicate, res.Status)
+
+	res, err = repo.CreateOrder(ctx, resent(), model.ConflictIgnore)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveIgnored, res.Status)
+
+	res, err = repo.CreateOrder(ctx, resent(), model.ConflictReject)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveConflict, res.Status)
+
+	// The version in the payload is not trusted: it neither blocks nor forces
+	// the replace, and the stored version is assigned by the database.
+	replaced := resent()
+	replaced.TrackNumber = "NEWTRACK"
+	replaced.Version = 5
+	res, err = repo.CreateOrder(ctx, replaced, model.ConflictReplace)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveUpdated, res.Status)
+	assert.Equal(t, 2, replaced.Version)
+	require.NotNil(t, res.Previous)
+	assert.Equal(t, "WBILMTESTTRACK", res.Previous.TrackNumber)
+	assert.Equal(t, "conflict-1", res.Previous.Transaction)
+	assert.Equal(t, []string{"ab4219087a764ae0btest"}, res.Previous.Rids)
+
+	got, err := repo.GetOrder(ctx, "conflict-1")
+	require.NoError(t, err)
+	assert.Equal(t, "Tel Aviv", got.Delivery.City)
+	assert.Equal(t, 2, got.Version)
+	assert.Len(t, got.Items, 1)
+}
+
+func TestPostgresRepository_Outbox(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC)
+	createTestOrder(t, repo, testOrder("outbox-1", createdAt))
+	_, err := repo.CreateOrders(ctx, []*model.Order{testOrder("outbox-2", createdAt)}, model.ConflictIgnore)
+	require.NoError(t, err)
+	_, err = repo.CreateOrder(ctx, testOrder("outbox-1", createdAt), model.ConflictIgnore)
+	require.NoError(t, err)
+
+	msgs, err := repo.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	assert.Equal(t, "outbox-1", msgs[0].Key)
+	assert.Equal(t, "outbox-2", msgs[1].Key)
+	assert.Equal(t, model.EventOrderCreated, msgs[0].EventType)
+	assert.Equal(t, 1, msgs[0].Attempts)
+
+	var event model.OrderEvent
+	require.NoError(t, json.Unmarshal(msgs[0].Payload, &event))
+	assert.Equal(t, "outbox-1", event.Order.OrderUID)
+	assert.Equal(t, 1, event.Version)
+
+	leased, err := repo.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	assert.Empty(t, leased)
+
+	require.NoError(t, repo.MarkOutboxFailed(ctx, msgs[1].ID, 0, "broker unavailable"))
+	require.NoError(t, repo.MarkOutboxPublished(ctx, []int64{msgs[0].ID}))
+
+	retried, err := repo.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, retried, 1)
+	assert.Equal(t, msgs[1].ID, retried[0].ID)
+	assert.Equal(t, 2, retried[0].Attempts)
+
+	deleted, err := repo.DeletePublishedOutbox(ctx, time.Now().Add(time.Minute))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func TestPostgresRepository_IngestionLedger(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC)
+
+	_, err := repo.GetIngestion(ctx, "orders", 0, 1)
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	first := model.MessageSource{Topic: "orders", Partition: 0, Offset: 1, PayloadHash: "h1"}
+	res, err := repo.IngestOrder(ctx, testOrder("ledger-1", createdAt), first, model.ConflictIgnore)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveInserted, res.Status)
+
+	resent := testOrder("ledger-1", createdAt)
+	resent.Delivery.City = "Tel Aviv"
+	res, err = repo.IngestOrder(ctx, resent, model.MessageSource{Topic: "orders", Partition: 1, Offset: 9, PayloadHash: "h2"},
+		model.ConflictIgnore)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveIgnored, res.Status)
+
+	res, err = repo.IngestOrder(ctx, resent, model.MessageSource{Topic: "orders", Partition: 1, Offset: 10, PayloadHash: "h3"},
+		model.ConflictReplace)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveUpdated, res.Status)
+
+	got, err := repo.GetIngestion(ctx, "orders", 0, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "h1", got.PayloadHash)
+
+	records, err := repo.ListIngestions(ctx, "ledger-1")
+	require.NoError(t, err)
+	require.Len(t, records, 3)
+	assert.Equal(t, int64(1), records[0].Offset)
+	assert.False(t, records[0].Conflicting)
+	assert.True(t, records[1].Conflicting)
+	assert.True(t, records[2].Conflicting)
+
+	_, err = repo.IngestOrder(ctx, testOrder("ledger-2", createdAt),
+		model.MessageSource{Topic: "orders", Partition: 0, Offset: 2, PayloadHash: "h4"}, model.ConflictIgnore)
+	require.NoError(t, err)
+
+	deleted, err := repo.DeleteIngestions(ctx, time.Now().Add(time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	deleted, err = repo.DeleteIngestions(ctx, time.Now().Add(time.Hour), 2)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), deleted)
+	deleted, err = repo.DeleteIngestions(ctx, time.Now().Add(-time.Hour), 2)
+	require.NoError(t, err)
+	assert.Zero(t, deleted)
+}
+
+func TestPostgresRepository_EraseOrder(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	createdAt := time.Date(2023, 11, 26, 12, 0, 0, 0, time.UTC)
+	createTestOrder(t, repo, testOrder("erase-1", createdAt))
+	createTestOrder(t, repo, testOrder("erase-2", createdAt))
+
+	require.NoError(t, repo.UpdateOrderStatus(ctx, &model.StatusChange{
+		OrderUID: "erase-1", From: model.StatusCreated, To: model.StatusPaid, Reason: "paid by Test Testov",
+	}))
+
+	anonymize := &model.Erasure{OrderUID: "erase-1", Mode: model.ErasureAnonymize, RequestedBy: "dpo", Reason: "user request"}
+	require.NoError(t, repo.EraseOrder(ctx, anonymize))
+	assert.NotZero(t, anonymize.ID)
+
+	got, err := repo.GetOrder(ctx, "erase-1")
+	require.NoError(t, err)
+	assert.Empty(t, got.Delivery.Name)
+	assert.Empty(t, got.Delivery.Email)
+	assert.Empty(t, got.CustomerID)
+	assert.Equal(t, 1817, got.Payment.Amount)
+	require.Len(t, got.Items, 1)
+
+	history, err := repo.GetStatusHistory(ctx, "erase-1")
+	require.NoError(t, err)
+	require.NotEmpty(t, history)
+	for _, h := range history {
+		assert.Empty(t, h.Reason)
+	}
+
+	msgs, err := repo.ClaimOutbox(ctx, 10, time.Minute)
+	require.NoError(t, err)
+	require.Len(t, msgs, 2)
+	var event model.OrderEvent
+	require.NoError(t, json.Unmarshal(msgs[0].Payload, &event))
+	assert.Empty(t, event.Order.Delivery.Phone)
+
+	require.NoError(t, repo.EraseOrder(ctx, &model.Erasure{OrderUID: "erase-2", Mode: model.ErasureDelete, RequestedBy: "dpo"}))
+	_, err = repo.GetOrder(ctx, "erase-2")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	err = repo.EraseOrder(ctx, &model.Erasure{OrderUID: "erase-2", Mode: model.ErasureDelete, RequestedBy: "dpo"})
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	// Neither a replayed original nor a changed copy brings the data back.
+	for _, uid := range []string{"erase-1", "erase-2"} {
+		for _, policy := range []model.ConflictPolicy{model.ConflictIgnore, model.ConflictReplace} {
+			res, err := repo.CreateOrder(ctx, testOrder(uid, createdAt), policy)
+			require.NoError(t, err)
+			assert.Equal(t, model.SaveErased, res.Status, "%s %s", uid, policy)
+		}
+	}
+	got, err = repo.GetOrder(ctx, "erase-1")
+	require.NoError(t, err)
+	assert.Empty(t, got.Delivery.Name)
+	_, err = repo.GetOrder(ctx, "erase-2")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+	_, err = repo.GetOrderUIDByTransaction(ctx, "erase-2")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+
+	erasures, err := repo.ListErasures(ctx, "erase-1")
+	require.NoError(t, err)
+	require.Len(t, erasures, 1)
+	assert.Equal(t, "dpo", erasures[0].RequestedBy)
+	assert.Equal(t, model.ErasureAnonymize, erasures[0].Mode)
+}
+
+func TestPostgresRepository_ArchiveOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	old := time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC)
+	recent := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	oldOrder := func() *model.Order {
+		o := testOrder("archive-old", old)
+		o.TrackNumber = "ARCHIVEDTRACK"
+		o.Items[0].TrackNumber = "ARCHIVEDTRACK"
+		o.Items[0].Rid = "archived-rid"
+		return o
+	}
+	createTestOrder(t, repo, oldOrder())
+	createTestOrder(t, repo, testOrder("archive-recent", recent))
+
+	uids, err := repo.ArchiveOrders(ctx, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"archive-old"}, uids)
+
+	got, err := repo.GetOrder(ctx, "archive-old")
+	require.NoError(t, err)
+	assert.True(t, got.Archived)
+	assert.Equal(t, "Test Testov", got.Delivery.Name)
+	require.Len(t, got.Items, 1)
+
+	hot, err := repo.GetOrder(ctx, "archive-recent")
+	require.NoError(t, err)
+	assert.False(t, hot.Archived)
+
+	page, err := repo.ListOrders(ctx, model.OrderFilter{Limit: 10})
+	require.NoError(t, err)
+	for _, o := range page.Orders {
+		assert.NotEqual(t, "archive-old", o.OrderUID)
+	}
+
+	history, err := repo.GetStatusHistory(ctx, "archive-old")
+	require.NoError(t, err)
+	assert.NotEmpty(t, history)
+
+	for _, lookup := range []func() (string, error){
+		func() (string, error) { return repo.GetOrderUIDByTrackNumber(ctx, got.TrackNumber) },
+		func() (string, error) { return repo.GetOrderUIDByTransaction(ctx, got.Payment.Transaction) },
+		func() (string, error) { return repo.GetOrderUIDByRid(ctx, got.Items[0].Rid) },
+	} {
+		uid, err := lookup()
+		require.NoError(t, err)
+		assert.Equal(t, "archive-old", uid)
+	}
+
+	res, err := repo.CreateOrder(ctx, oldOrder(), model.ConflictReplace)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveDuplicate, res.Status)
+
+	changed := oldOrder()
+	changed.Delivery.City = "Haifa"
+	res, err = repo.CreateOrder(ctx, changed, model.ConflictReplace)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveConflict, res.Status)
+
+	got, err = repo.GetOrder(ctx, "archive-old")
+	require.NoError(t, err)
+	assert.True(t, got.Archived)
+
+	require.NoError(t, repo.EraseOrder(ctx, &model.Erasure{OrderUID: "archive-old", Mode: model.ErasureDelete, RequestedBy: "dpo"}))
+	_, err = repo.GetOrder(ctx, "archive-old")
+	assert.ErrorIs(t, err, model.ErrNotFound)
+}
+
+func TestPostgresRepository_Partitions(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	jan := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	require.NoError(t, repo.CreatePartitions(ctx, jan, jan.AddDate(0, 1, 0)))
+	require.NoError(t, repo.CreatePartitions(ctx, jan, jan.AddDate(0, 1, 0)))
+
+	createTestOrder(t, repo, testOrder("partition-old", jan.AddDate(0, 0, 14)))
+	createTestOrder(t, repo, testOrder("partition-new", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	// The partition of an order outside the premade months is created on
+	// insert, so premaking that month later still works.
+	require.NoError(t, repo.CreatePartitions(ctx, time.Date(2023, 12, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)))
+
+	dropped, err := repo.DropPartitions(ctx, jan.AddDate(0, 1, 0))
+	require.Error(t, err)
+	assert.Empty(t, dropped)
+
+	uids, err := repo.ArchiveOrders(ctx, jan.AddDate(0, 1, 0), 10)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"partition-old"}, uids)
+
+	dropped, err = repo.DropPartitions(ctx, jan.AddDate(0, 1, 0))
+	require.NoError(t, err)
+	assert.Equal(t, []string{"202001"}, dropped)
+
+	old, err := repo.GetOrder(ctx, "partition-old")
+	require.NoError(t, err)
+	assert.True(t, old.Archived)
+	_, err = repo.GetOrder(ctx, "partition-new")
+	require.NoError(t, err)
+
+	res, err := repo.CreateOrder(ctx, testOrder("partition-old", jan.AddDate(0, 1, 3)), model.ConflictIgnore)
+	require.NoError(t, err)
+	assert.Equal(t, model.SaveIgnored, res.Status)
+}
+
+// TestMigrations runs the real migrations over data written before orders were
+// partitioned and checks the repository against the result.
+func TestMigrations(t *testing.T) {
+	ctx := context.Background()
+	connStr := startPostgres(t)
+
+	m, err := migrate.New("file://../../../db/migrations", connStr)
+	require.NoError(t, err)
+	t.Cleanup(func() { _, _ = m.Close() })
+	require.NoError(t, m.Migrate(10))
+
+	pool, err := pgxpool.New(ctx, connStr)
+	require.NoError(t, err)
+	t.Cleanup(pool.Close)
+
+	// The second order is past the months premade by 000011 and lands in the
+	// default partition until 000014.
+	past := time.Date(2020, 1, 15, 0, 0, 0, 0, time.UTC)
+	future := time.Now().UTC().AddDate(2, 0, 0)
+	for uid, createdAt := range map[string]time.Time{"legacy-past": past, "legacy-future": future} {
+		_, err := pool.Exec(ctx, `
+			INSERT INTO orders (order_uid, track_number, entry, locale, customer_id, delivery_service,
+				shardkey, sm_id, date_created, oof_shard)
+			VALUES ($1, 'TRACK', 'WBIL', 'en', 'test', 'meest', '9', 99, $2, '1')`, uid, createdAt)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, `
+			INSERT INTO delivery (order_uid, name, phone, zip, city, address, region, email)
+			VALUES ($1, 'Test', '+9720000000', '2639809', 'City', 'Address', 'Region', 'test@gmail.com')`, uid)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, `
+			INSERT INTO payment (transaction, order_uid, currency, provider, amount, payment_dt, bank,
+				delivery_cost, goods_total, custom_fee)
+			VALUES ($1, $1, 'USD', 'wbpay', 1817, 1637907727, 'alpha', 1500, 317, 0)`, uid)
+		require.NoError(t, err)
+		_, err = pool.Exec(ctx, `
+			INSERT INTO items (order_uid, chrt_id, track_number, price, rid, name, sale, size,
+				total_price, nm_id, brand, status)
+			VALUES ($1, 1, 'TRACK', 453, $1, 'Mascaras', 30, '0', 317, 2389212, 'Vivienne Sabo', 202)`, uid)
+		require.NoError(t, err)
+	}
+
+	require.NoError(t, m.Up())
+
+	repo := New(pool, noop.NewTracerProvider().Tracer("test"))
+	for _, uid := range []string{"legacy-past", "legacy-future"} {
+		got, err := repo.GetOrder(ctx, uid)
+		require.NoError(t, err)
+		assert.Len(t, got.Items, 1)
+
+		byTx, err := repo.GetOrderUIDByTransaction(ctx, uid)
+		require.NoError(t, err)
+		assert.Equal(t, uid, byTx)
+	}
+	require.NoError(t, repo.CreatePartitions(ctx, future, future.AddDate(0, 1, 0)))
+
+	createTestOrder(t, repo, testOrder("migrated-new", time.Now()))
+	dup := testOrder("migrated-dup", time.Now())
+	dup.Payment.Transaction = "legacy-past"
+	_, err = repo.CreateOrder(ctx, dup, model.ConflictIgnore)
+	assert.ErrorIs(t, err, model.ErrOrderConflict)
+
+	require.NoError(t, m.Down())
+}
+
+func TestPostgresRepository_ListOrders(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i := 0; i < 5; i++ {
+		order := testOrder("list-"+strconv.Itoa(i), base.Add(time.Duration(i)*time.Hour))
+		order.Payment.Amount = 100 * (i + 1)
+		if i%2 == 0 {
+			order.CustomerID = "even"
+			order.Items[0].Brand = "Even Brand"
+		}
+		createTestOrder(t, repo, order)
+	}
+
+	t.Run("pagination", func(t *testing.T) {
+		var uids []string
+		filter := model.OrderFilter{Limit: 2}
+		for {
+			page, err := repo.ListOrders(ctx, filter)
+			require.NoError(t, err)
+			for _, o := range page.Orders {
+				uids = append(uids, o.OrderUID)
+				assert.Len(t, o.Items, 1)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			filter.Cursor = page.NextCursor
+		}
+		assert.Equal(t, []string{"list-4", "list-3", "list-2", "list-1", "list-0"}, uids)
+	})
+
+	t.Run("filters_and_sort", func(t *testing.T) {
+		page, err := repo.ListOrders(ctx, model.OrderFilter{
+			CustomerID:  "even",
+			ItemBrand:   "Even Brand",
+			CreatedFrom: base.Add(time.Hour),
+			Sort:        model.SortAmountAsc,
+		})
+		require.NoError(t, err)
+		require.Len(t, page.Orders, 2)
+		assert.Equal(t, "list-2", page.Orders[0].OrderUID)
+		assert.Equal(t, "list-4", page.Orders[1].OrderUID)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("cursor_for_other_sort", func(t *testing.T) {
+		page, err := repo.ListOrders(ctx, model.OrderFilter{Limit: 1})
+		require.NoError(t, err)
+
+		_, err = repo.ListOrders(ctx, model.OrderFilter{Sort: model.SortAmountAsc, Cursor: page.NextCursor})
+		assert.ErrorIs(t, err, model.ErrInvalidCursor)
+	})
+}
+
+func TestPostgresRepository_UpdateOrderStatus(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	order := testOrder("status-1", time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	createTestOrder(t, repo, order)
+
+	change := &model.StatusChange{OrderUID: order.OrderUID, From: model.StatusCreated, To: model.StatusPaid, Reason: "paid"}
+	require.NoError(t, repo.UpdateOrderStatus(ctx, change))
+	assert.False(t, change.ChangedAt.IsZero())
+
+	status, err := repo.GetOrderStatus(ctx, order.OrderUID)
+	require.NoError(t, err)
+	assert.Equal(t, model.StatusPaid, status)
+
+	stale := &model.StatusChange{OrderUID: order.OrderUID, From: model.StatusCreated, To: model.StatusCancelled}
+	assert.ErrorIs(t, repo.UpdateOrderStatus(ctx, stale), model.ErrStatusConflict)
+
+	missing := &model.StatusChange{OrderUID: "missing", From: model.StatusCreated, To: model.StatusPaid}
+	assert.ErrorIs(t, repo.UpdateOrderStatus(ctx, missing), model.ErrNotFound)
+
+	cancelled := &model.StatusChange{EventID: "evt-1", OrderUID: order.OrderUID, From: model.StatusPaid, To: model.StatusCancelled}
+	require.NoError(t, repo.UpdateOrderStatus(ctx, cancelled))
+	applied, err := repo.StatusEventApplied(ctx, "evt-1")
+	require.NoError(t, err)
+	assert.True(t, applied)
+
+	replayed := &model.StatusChange{EventID: "evt-1", OrderUID: order.OrderUID, From: model.StatusCancelled, To: model.StatusReturned}
+	assert.ErrorIs(t, repo.UpdateOrderStatus(ctx, replayed), model.ErrDuplicateEvent)
+
+	history, err := repo.GetStatusHistory(ctx, order.OrderUID)
+	require.NoError(t, err)
+	require.Len(t, history, 3)
+	assert.Equal(t, "evt-1", history[2].EventID)
+	assert.Equal(t, model.OrderStatus(""), history[0].From)
+	assert.Equal(t, model.StatusCreated, history[0].To)
+	assert.Equal(t, model.StatusPaid, history[1].To)
+	assert.Equal(t, "paid", history[1].Reason)
+}
+
+func BenchmarkRepository_CreateOrder(b *testing.B) {
+	ctx := context.Background()
+	repo := newTestRepository(b)
+	createdAt := time.Now().UTC()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := repo.CreateOrder(ctx, testOrder("single-"+strconv.Itoa(i), createdAt), model.ConflictIgnore); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRepository_CreateOrders(b *testing.B) {
+	const batchSize = 100
+
+	ctx := context.Background()
+	repo := newTestRepository(b)
+	createdAt := time.Now().UTC()
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i += batchSize {
+		orders := make([]*model.Order, 0, batchSize)
+		for j := i; j < i+batchSize && j < b.N; j++ {
+			orders = append(orders, testOrder("batch-"+strconv.Itoa(j), createdAt))
+		}
+		if _, err := repo.CreateOrders(ctx, orders, model.ConflictIgnore); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRepository_GetOrder(b *testing.B) {
+	ctx := context.Background()
+	repo := newTestRepository(b)
+	order := testOrder("bench-get", time.Now().UTC())
+	for i := 1; i < 5; i++ {
+		item := order.Items[0]
+		item.ChrtID += i
+		item.Rid += strconv.Itoa(i)
+		order.Items = append(order.Items, item)
+	}
+	createTestOrder(b, repo, order)
+
+	for _, mode := range []ReadMode{ReadJoins, ReadJSON} {
+		b.Run(string(mode), func(b *testing.B) {
+			r := New(repo.pool, repo.tr, WithReadMode(mode))
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := r.GetOrder(ctx, order.OrderUID); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,195 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const replicaPingTimeout = 2 * time.Second
+
+// qReplicaLag returns how far behind the primary the replica has applied WAL,
+// in seconds. A replica that has applied everything it received is not behind.
+const qReplicaLag = `
+	SELECT COALESCE(
+		CASE WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+		ELSE EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()) END,
+	0)::float8
+`
+
+// querier is implemented by *pgxpool.Pool and pgx.Tx.
+type querier interface {
+	Query(ctx context.Context, sql string, args ...any) (pgx.Rows, error)
+	QueryRow(ctx context.Context, sql string, args ...any) pgx.Row
+}
+
+type replica struct {
+	pool    *pgxpool.Pool
+	healthy atomic.Bool
+	lagging atomic.Bool
+}
+
+// writeLog remembers orders written by this instance for a short window, so
+// that reading them back does not hit a replica that is still behind.
+type writeLog struct {
+	mu     sync.Mutex
+	window time.Duration
+	at     map[string]time.Time
+}
+
+type Option func(*Repository)
+
+// WithReplicas routes read queries to the given replica pools. Replicas that
+// fail a health check or a query are skipped until the next successful check.
+func WithReplicas(pools []*pgxpool.Pool) Option {
+	return func(r *Repository) {
+		for _, pool := range pools {
+			rep := &replica{pool: pool}
+			rep.healthy.Store(true)
+			r.replicas = append(r.replicas, rep)
+		}
+	}
+}
+
+// WithReadYourWrites sends reads of an order to the primary for window after
+// the order was written.
+func WithReadYourWrites(window time.Duration) Option {
+	return func(r *Repository) {
+		r.writes.window = window
+	}
+}
+
+// WithMaxReplicaLag sets how far a replica may be behind the primary before a
+// row missing on it is looked up on the primary as well.
+func WithMaxReplicaLag(lag time.Duration) Option {
+	return func(r *Repository) {
+		r.maxLag = lag
+	}
+}
+
+// read runs fn against a replica and falls back to the primary when no
+// replica is available or the replica fails. A row that is not found on the
+// replica is looked up on the primary only if the replica is lagging.
+func (r *Repository) read(ctx context.Context, orderUID string, fn func(db querier) error) error {
+	rep := r.replica(orderUID)
+	if rep == nil {
+		return fn(r.pool)
+	}
+
+	span := trace.SpanFromContext(ctx)
+	span.SetAttributes(attribute.Bool("db.replica", true))
+	err := fn(rep.pool)
+	if err == nil || ctx.Err() != nil {
+		return err
+	}
+	if errors.Is(err, model.ErrNotFound) {
+		if !rep.lagging.Load() {
+			return err
+		}
+	} else {
+		rep.healthy.Store(false)
+		slog.Warn("database replica query failed, using primary", slog.String("error", err.Error()))
+	}
+	span.SetAttributes(attribute.Bool("db.replica", false))
+	return fn(r.pool)
+}
+
+func (r *Repository) replica(orderUID string) *replica {
+	if len(r.replicas) == 0 || (orderUID != "" && r.recentlyWritten(orderUID)) {
+		return nil
+	}
+
+	n := uint64(len(r.replicas))
+	start := r.next.Add(1)
+	for i := range n {
+		if rep := r.replicas[(start+i)%n]; rep.healthy.Load() {
+			return rep
+		}
+	}
+	return nil
+}
+
+func (r *Repository) wrote(orderUIDs ...string) {
+	if len(r.replicas) == 0 || r.writes.window <= 0 {
+		return
+	}
+
+	now := time.Now()
+	r.writes.mu.Lock()
+	defer r.writes.mu.Unlock()
+	if r.writes.at == nil {
+		r.writes.at = make(map[string]time.Time)
+	}
+	for _, uid := range orderUIDs {
+		r.writes.at[uid] = now
+	}
+}
+
+func (r *Repository) recentlyWritten(orderUID string) bool {
+	r.writes.mu.Lock()
+	defer r.writes.mu.Unlock()
+	at, ok := r.writes.at[orderUID]
+	return ok && time.Since(at) < r.writes.window
+}
+
+// CheckReplicas queries every replica for its lag and updates its health.
+func (r *Repository) CheckReplicas(ctx context.Context) {
+	for i, rep := range r.replicas {
+		pingCtx, cancel := context.WithTimeout(ctx, replicaPingTimeout)
+		var lag float64
+		err := rep.pool.QueryRow(pingCtx, qReplicaLag).Scan(&lag)
+		cancel()
+
+		if err == nil {
+			lagging := time.Duration(lag*float64(time.Second)) > r.maxLag
+			if rep.lagging.Swap(lagging) != lagging {
+				if lagging {
+					slog.Warn("database replica is lagging", slog.Int("replica", i), slog.Float64("lag_seconds", lag))
+				} else {
+					slog.Info("database replica caught up", slog.Int("replica", i))
+				}
+			}
+		}
+
+		healthy := err == nil
+		if rep.healthy.Swap(healthy) != healthy {
+			if healthy {
+				slog.Info("database replica is healthy", slog.Int("replica", i))
+			} else {
+				slog.Warn("database replica is unhealthy", slog.Int("replica", i), slog.String("error", err.Error()))
+			}
+		}
+	}
+
+	r.writes.mu.Lock()
+	for uid, at := range r.writes.at {
+		if time.Since(at) >= r.writes.window {
+			delete(r.writes.at, uid)
+		}
+	}
+	r.writes.mu.Unlock()
+}
+
+// StartReplicaChecks runs CheckReplicas every interval until ctx is cancelled.
+func (r *Repository) StartReplicaChecks(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			r.CheckReplicas(ctx)
+		}
+	}
+}
@@ -0,0 +1,96 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func newReplicaRepository(replicas int, window time.Duration) *Repository {
+	pools := make([]*pgxpool.Pool, replicas)
+	for i := range pools {
+		pools[i] = new(pgxpool.Pool)
+	}
+	return New(new(pgxpool.Pool), noop.NewTracerProvider().Tracer("test"),
+		WithReplicas(pools), WithReadYourWrites(window))
+}
+
+func TestRepository_ReplicaRouting(t *testing.T) {
+	t.Run("round robin over healthy replicas", func(t *testing.T) {
+		r := newReplicaRepository(2, time.Minute)
+		r.replicas[0].healthy.Store(false)
+
+		for range 3 {
+			assert.Same(t, r.replicas[1], r.replica(""))
+		}
+	})
+
+	t.Run("primary when all replicas are down", func(t *testing.T) {
+		r := newReplicaRepository(2, time.Minute)
+		for _, rep := range r.replicas {
+			rep.healthy.Store(false)
+		}
+		assert.Nil(t, r.replica(""))
+	})
+
+	t.Run("read your writes", func(t *testing.T) {
+		r := newReplicaRepository(1, time.Minute)
+		r.wrote("034")
+
+		assert.Nil(t, r.replica("034"))
+		assert.NotNil(t, r.replica("035"))
+	})
+
+	t.Run("write window expires", func(t *testing.T) {
+		r := newReplicaRepository(1, time.Nanosecond)
+		r.wrote("034")
+		time.Sleep(time.Millisecond)
+
+		assert.NotNil(t, r.replica("034"))
+	})
+}
+
+func TestRepository_ReadFallback(t *testing.T) {
+	tests := []struct {
+		name        string
+		replicaErr  error
+		lagging     bool
+		wantErr     error
+		wantPrimary bool
+		wantHealthy bool
+	}{
+		{name: "replica error", replicaErr: errors.New("connection refused"), wantPrimary: true, wantHealthy: false},
+		{name: "replica lag", replicaErr: model.ErrNotFound, lagging: true, wantPrimary: true, wantHealthy: true},
+		{name: "not found on a replica in sync", replicaErr: model.ErrNotFound, wantErr: model.ErrNotFound, wantHealthy: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newReplicaRepository(1, time.Minute)
+			r.replicas[0].lagging.Store(tt.lagging)
+
+			var calls []querier
+			err := r.read(context.Background(), "", func(db querier) error {
+				calls = append(calls, db)
+				if db == querier(r.replicas[0].pool) {
+					return tt.replicaErr
+				}
+				return nil
+			})
+
+			want := []querier{r.replicas[0].pool}
+			if tt.wantPrimary {
+				want = append(want, r.pool)
+			}
+			assert.ErrorIs(t, err, tt.wantErr)
+			assert.Equal(t, want, calls)
+			assert.Equal(t, tt.wantHealthy, r.replicas[0].healthy.Load())
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const uniqueViolation = "23505"
+
+func (r *Repository) GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error) {
+	const op = "postgresql.GetOrderStatus"
+
+	ctx, span := r.tr.Start(ctx, "db.select.orders.status")
+	defer span.End()
+
+	var status model.OrderStatus
+	err := r.pool.QueryRow(ctx, `SELECT status FROM orders WHERE order_uid = $1`, orderUID).Scan(&status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", model.ErrNotFound
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return "", fmt.Errorf("%s: %w", op, err)
+	}
+	return status, nil
+}
+
+func (r *Repository) UpdateOrderStatus(ctx context.Context, change *model.StatusChange) error {
+	const op = "postgresql.UpdateOrderStatus"
+
+	ctx, span := r.tr.Start(ctx, "db.update.orders.status")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("order_uid", change.OrderUID),
+		attribute.String("from", string(change.From)),
+		attribute.String("to", string(change.To)),
+	)
+
+	tx, err := r.pool.Begin(ctx)
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	defer func() {
+		_ = tx.Rollback(ctx)
+	}()
+
+	t, err := tx.Exec(ctx, `UPDATE orders SET status = $1 WHERE order_uid = $2 AND status = $3`,
+		change.To, change.OrderUID, change.From)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if t.RowsAffected() == 0 {
+		var exists bool
+		if err := tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM orders WHERE order_uid = $1)`, change.OrderUID).Scan(&exists); err != nil {
+			return fmt.Errorf("%s: %w", op, err)
+		}
+		if !exists {
+			return model.ErrNotFound
+		}
+		return model.ErrStatusConflict
+	}
+
+	qHistory := `
+		INSERT INTO order_status_history (order_uid, from_status, to_status, reason, event_id)
+			VALUES ($1, NULLIF($2, ''), $3, $4, NULLIF($5, ''))
+			RETURNING changed_at
+	`
+	err = tx.QueryRow(ctx, qHistory, change.OrderUID, change.From, change.To, change.Reason, change.EventID).
+		Scan(&change.ChangedAt)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return model.ErrDuplicateEvent
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	r.wrote(change.OrderUID)
+	return nil
+}
+
+func (r *Repository) StatusEventApplied(ctx context.Context, eventID string) (bool, error) {
+	const op = "postgresql.StatusEventApplied"
+
+	ctx, span := r.tr.Start(ctx, "db.select.order_status_history.event")
+	defer span.End()
+
+	var applied bool
+	err := r.pool.QueryRow(ctx,
+		`SELECT EXISTS (SELECT 1 FROM order_status_history WHERE event_id = $1)`, eventID).Scan(&applied)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return false, fmt.Errorf("%s: %w", op, err)
+	}
+	return applied, nil
+}
+
+func (r *Repository) GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	const op = "postgresql.GetStatusHistory"
+
+	ctx, span := r.tr.Start(ctx, "db.select.order_status_history")
+	defer span.End()
+
+	q := `
+		SELECT COALESCE(event_id, ''), order_uid, COALESCE(from_status, ''), to_status, reason, changed_at
+		FROM order_status_history
+		WHERE order_uid = $1
+		ORDER BY id
+	`
+	rows, err := r.pool.Query(ctx, q, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	defer rows.Close()
+
+	history := make([]model.StatusChange, 0)
+	for rows.Next() {
+		var c model.StatusChange
+		if err := rows.Scan(&c.EventID, &c.OrderUID, &c.From, &c.To, &c.Reason, &c.ChangedAt); err != nil {
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		history = append(history, c)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(history) == 0 {
+		if _, err := r.GetOrderStatus(ctx, orderUID); err != nil {
+			return nil, err
+		}
+	}
+	return history, nil
+}
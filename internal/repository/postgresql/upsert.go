@@ -0,0 +1,136 @@
+package postgresql
+
+import (
+	"context"
+	"errors"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/jackc/pgx/v5"
+)
+
+const qUpdateOrder = `
+	UPDATE orders SET
+		track_number = $2, entry = $3, locale = $4, internal_signature = $5,
+		customer_id = $6, delivery_service = $7, shardkey = $8, sm_id = $9,
+		date_created = $10, oof_shard = $11, content_hash = $12, version = version + 1
+	WHERE order_uid = $1 AND version = $13
+	RETURNING version, status
+`
+
+const qSecondaryKeys = `
+	SELECT o.track_number, p.transaction,
+		ARRAY(SELECT rid FROM items WHERE order_uid = o.order_uid AND date_created = o.date_created ORDER BY id)
+	FROM orders o
+	JOIN payment p ON p.order_uid = o.order_uid AND p.date_created = o.date_created
+	WHERE o.order_uid = $1
+`
+
+// resolveConflict handles an insert that hit an existing order_uid. An erased
+// order is never stored again. Orders
+// stored before content hashes were introduced have an empty hash and are
+// always treated as different. A replaced order reports the secondary keys it
+// had before, so that stale lookups can be invalidated.
+func (r *Repository) resolveConflict(ctx context.Context, tx pgx.Tx, order *model.Order, hash string, policy model.ConflictPolicy) (model.SaveResult, error) {
+	res := model.SaveResult{OrderUID: order.OrderUID, Status: model.SaveFailed}
+
+	var erased bool
+	err := tx.QueryRow(ctx, `SELECT erased_at IS NOT NULL FROM order_keys WHERE order_uid = $1`, order.OrderUID).Scan(&erased)
+	if err != nil {
+		return res, err
+	}
+	if erased {
+		res.Status = model.SaveErased
+		return res, nil
+	}
+
+	var (
+		version    int
+		storedHash string
+		status     model.OrderStatus
+	)
+	err = tx.QueryRow(ctx, `SELECT version, content_hash, status FROM orders WHERE order_uid = $1`, order.OrderUID).
+		Scan(&version, &storedHash, &status)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return resolveArchived(ctx, tx, order, hash, policy)
+	}
+	if err != nil {
+		return res, err
+	}
+
+	if storedHash == hash {
+		order.Version = version
+		order.Status = status
+		res.Status = model.SaveDuplicate
+		return res, nil
+	}
+
+	switch policy {
+	case model.ConflictReplace:
+	case model.ConflictReject:
+		res.Status = model.SaveConflict
+		return res, nil
+	default:
+		res.Status = model.SaveIgnored
+		return res, nil
+	}
+
+	var prev model.SecondaryKeys
+	err = tx.QueryRow(ctx, qSecondaryKeys, order.OrderUID).Scan(&prev.TrackNumber, &prev.Transaction, &prev.Rids)
+	if err != nil {
+		return res, err
+	}
+
+	// The details reference the order by (order_uid, date_created), so they
+	// are removed before date_created may change.
+	for _, q := range []string{
+		`DELETE FROM delivery WHERE order_uid = $1`,
+		`DELETE FROM payment WHERE order_uid = $1`,
+		`DELETE FROM transaction_keys WHERE order_uid = $1`,
+		`DELETE FROM items WHERE order_uid = $1`,
+	} {
+		if _, err := tx.Exec(ctx, q, order.OrderUID); err != nil {
+			return res, err
+		}
+	}
+
+	// The version read above, not the one in the payload, guards the update,
+	// so a concurrent replace makes this one fail with ErrVersionConflict.
+	args := orderArgs(order)
+	args = append(args[:len(args)-1], hash, version)
+	err = tx.QueryRow(ctx, qUpdateOrder, args...).Scan(&order.Version, &order.Status)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return res, model.ErrVersionConflict
+		}
+		return res, err
+	}
+
+	if err := insertOrderDetails(ctx, tx, order); err != nil {
+		return res, err
+	}
+	res.Status = model.SaveUpdated
+	res.Previous = &prev
+	return res, nil
+}
+
+// resolveArchived handles a re-sent order that has been archived. Archived
+// orders are read-only, so ConflictReplace rejects a changed copy as well.
+func resolveArchived(ctx context.Context, tx pgx.Tx, order *model.Order, hash string, policy model.ConflictPolicy) (model.SaveResult, error) {
+	res := model.SaveResult{OrderUID: order.OrderUID, Status: model.SaveFailed}
+
+	var storedHash string
+	err := tx.QueryRow(ctx, `SELECT content_hash FROM orders_archive WHERE order_uid = $1`, order.OrderUID).Scan(&storedHash)
+	if err != nil {
+		return res, err
+	}
+
+	switch {
+	case storedHash == hash:
+		res.Status = model.SaveDuplicate
+	case policy == model.ConflictReplace, policy == model.ConflictReject:
+		res.Status = model.SaveConflict
+	default:
+		res.Status = model.SaveIgnored
+	}
+	return res, nil
+}
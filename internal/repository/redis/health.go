@@ -0,0 +1,230 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	pingTimeout = 2 * time.Second
+
+	// maxStaleKeys bounds the keys remembered while degraded. Past it every key
+	// not protected by WithKeepOnPurge is deleted on recovery instead.
+	maxStaleKeys = 100_000
+	purgeBatch   = 1000
+)
+
+// Degraded reports whether Redis is currently unreachable. In degraded mode
+// Get always misses, SetNX fails with ErrUnavailable, and Set and Delete are
+// skipped; the keys they touch are deleted before degraded mode ends, so no
+// value older than the outage is served afterwards.
+func (r *Redis) Degraded() bool {
+	return r.degraded.Load()
+}
+
+func (r *Redis) setDegraded(degraded bool) {
+	if r.degraded.Swap(degraded) == degraded {
+		return
+	}
+	if degraded {
+		slog.Warn("redis is unreachable, cache is degraded")
+	} else {
+		slog.Info("redis is reachable again, cache restored")
+	}
+	if r.onState != nil {
+		r.onState(degraded)
+	}
+}
+
+// skip reports whether a write to key has to be skipped because Redis is
+// degraded, and remembers the key for recover.
+func (r *Redis) skip(key string) bool {
+	if !r.Degraded() {
+		return false
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !r.Degraded() {
+		return false
+	}
+	r.remember(key)
+	r.probe()
+	return true
+}
+
+// remember must be called with mu held.
+func (r *Redis) remember(keys ...string) {
+	for _, key := range keys {
+		if len(r.stale) >= maxStaleKeys {
+			r.overflow = true
+			return
+		}
+		r.stale[key] = struct{}{}
+	}
+}
+
+// check switches to degraded mode as soon as a command fails because Redis
+// cannot be reached, without waiting for the next health check. The keys the
+// command wrote are remembered, since the write may or may not have landed.
+func (r *Redis) check(err error, keys ...string) error {
+	var reply redis.Error
+	if err == nil || errors.Is(err, redis.Nil) || errors.As(err, &reply) ||
+		errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	r.mu.Lock()
+	r.remember(keys...)
+	r.setDegraded(true)
+	r.mu.Unlock()
+	r.probe()
+	return err
+}
+
+// probe asks StartHealthChecks to ping Redis without waiting out the backoff.
+func (r *Redis) probe() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// recover deletes the keys written while Redis was degraded and leaves
+// degraded mode once none are left.
+func (r *Redis) recover(ctx context.Context) error {
+	for {
+		r.mu.Lock()
+		keys, overflow := r.stale, r.overflow
+		if len(keys) == 0 && !overflow {
+			r.setDegraded(false)
+			r.mu.Unlock()
+			return nil
+		}
+		r.stale, r.overflow = make(map[string]struct{}), false
+		r.mu.Unlock()
+
+		if err := r.purge(ctx, keys, overflow); err != nil {
+			r.mu.Lock()
+			r.remember(slices.Collect(maps.Keys(keys))...)
+			r.overflow = r.overflow || overflow
+			r.mu.Unlock()
+			return err
+		}
+	}
+}
+
+func (r *Redis) purge(ctx context.Context, keys map[string]struct{}, all bool) error {
+	if all {
+		return r.purgeAll(ctx)
+	}
+	slog.Info("deleting keys changed while redis was unreachable", slog.Int("keys", len(keys)))
+	for batch := range slices.Chunk(slices.Collect(maps.Keys(keys)), purgeBatch) {
+		if err := r.Client.Unlink(ctx, batch...).Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeAll deletes every key except those protected by WithKeepOnPurge.
+func (r *Redis) purgeAll(ctx context.Context) error {
+	slog.Warn("too many keys changed while redis was unreachable, deleting all cached keys")
+
+	batch := make([]string, 0, purgeBatch)
+	unlink := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := r.Client.Unlink(ctx, batch...).Err()
+		batch = batch[:0]
+		return err
+	}
+
+	iter := r.Client.Scan(ctx, 0, "", purgeBatch).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+		if slices.ContainsFunc(r.keep, func(prefix string) bool { return strings.HasPrefix(key, prefix) }) {
+			continue
+		}
+		batch = append(batch, key)
+		if len(batch) == purgeBatch {
+			if err := unlink(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	return unlink()
+}
+
+// CheckHealth pings Redis and updates the degraded state. Degraded mode ends
+// only after the keys written during the outage are deleted.
+func (r *Redis) CheckHealth(ctx context.Context, timeout time.Duration) error {
+	pingCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	err := r.Client.Ping(pingCtx).Err()
+	if err != nil && ctx.Err() != nil {
+		return err
+	}
+	if err != nil {
+		r.setDegraded(true)
+		return err
+	}
+	if r.Degraded() {
+		return r.recover(ctx)
+	}
+	return nil
+}
+
+// StartHealthChecks pings Redis every interval while it is healthy. Once a
+// ping fails, it retries with exponential backoff from minBackoff up to
+// maxBackoff until Redis is reachable again. A request that finds Redis
+// degraded cuts the wait short, but pings stay at least minBackoff apart.
+func (r *Redis) StartHealthChecks(ctx context.Context, interval, minBackoff, maxBackoff time.Duration) error {
+	backoff := minBackoff
+	var last time.Time
+	for {
+		degraded := r.Degraded()
+		wait := interval
+		if degraded {
+			wait = backoff
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		case <-r.wake:
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(time.Until(last.Add(minBackoff))):
+			}
+		}
+		last = time.Now()
+
+		if err := r.CheckHealth(ctx, pingTimeout); err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if degraded {
+				backoff = min(backoff*2, maxBackoff)
+			}
+			slog.Debug("redis health check failed",
+				slog.Duration("retry_in", backoff),
+				slog.String("error", err.Error()),
+			)
+			continue
+		}
+		backoff = minBackoff
+	}
+}
@@ -0,0 +1,55 @@
+package redis
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+// closedPort returns a local port nothing listens on.
+func closedPort(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := l.Addr().(*net.TCPAddr).Port
+	require.NoError(t, l.Close())
+	return strconv.Itoa(port)
+}
+
+func TestNew_Unreachable(t *testing.T) {
+	ctx := context.Background()
+	tr := noop.NewTracerProvider().Tracer("test")
+	port := closedPort(t)
+
+	_, err := New(ctx, "127.0.0.1", port, "", 0, tr)
+	assert.Error(t, err)
+
+	var states []bool
+	r, err := New(ctx, "127.0.0.1", port, "", 0, tr,
+		WithSoftFail(),
+		WithStateHook(func(degraded bool) { states = append(states, degraded) }),
+	)
+	require.NoError(t, err)
+	defer func() { _ = r.Close() }()
+
+	assert.True(t, r.Degraded())
+	assert.Equal(t, []bool{true}, states)
+
+	var dest string
+	assert.ErrorIs(t, r.Get(ctx, "key", &dest), ErrCacheMiss)
+	assert.NoError(t, r.Set(ctx, "key", "value", time.Minute))
+	assert.NoError(t, r.Delete(ctx, "key"))
+	assert.Contains(t, r.stale, "key")
+	_, err = r.SetNX(ctx, "key", "value", time.Minute)
+	assert.ErrorIs(t, err, ErrUnavailable)
+
+	assert.Error(t, r.CheckHealth(ctx, time.Second))
+	assert.True(t, r.Degraded())
+	assert.Equal(t, []bool{true}, states)
+}
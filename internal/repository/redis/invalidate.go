@@ -0,0 +1,79 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const invalidationChannel = "cache:invalidate"
+
+// GetWithTTL works like Get and also returns the remaining time to live of
+// the key, or 0 if the key has no expiry.
+func (r *Redis) GetWithTTL(ctx context.Context, key string, dest any) (time.Duration, error) {
+	if r.Degraded() {
+		r.probe()
+		return 0, ErrCacheMiss
+	}
+	ctx, span := r.tr.Start(ctx, "redis.GetWithTTL")
+	defer span.End()
+
+	pipe := r.Client.Pipeline()
+	get := pipe.Get(ctx, key)
+	pttl := pipe.PTTL(ctx, key)
+	if _, err := pipe.Exec(ctx); err != nil {
+		if errors.Is(err, redis.Nil) {
+			return 0, ErrCacheMiss
+		}
+		return 0, fmt.Errorf("failed to get key %s: %w", key, r.check(err))
+	}
+
+	data, err := get.Bytes()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get key %s: %w", key, err)
+	}
+	if err := json.Unmarshal(data, dest); err != nil {
+		return 0, err
+	}
+
+	ttl := pttl.Val()
+	if ttl < 0 {
+		ttl = 0
+	}
+	return ttl, nil
+}
+
+// PublishInvalidation broadcasts msg to every SubscribeInvalidations caller.
+func (r *Redis) PublishInvalidation(ctx context.Context, msg string) error {
+	if r.Degraded() {
+		return nil
+	}
+	ctx, span := r.tr.Start(ctx, "redis.PublishInvalidation")
+	defer span.End()
+	return r.check(r.Client.Publish(ctx, invalidationChannel, msg).Err())
+}
+
+// SubscribeInvalidations calls fn for every invalidation published until ctx
+// is done. The subscription reconnects on its own after Redis comes back;
+// messages published while it was down are lost.
+func (r *Redis) SubscribeInvalidations(ctx context.Context, fn func(msg string)) error {
+	sub := r.Client.Subscribe(ctx, invalidationChannel)
+	defer func() { _ = sub.Close() }()
+
+	ch := sub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case m, ok := <-ch:
+			if !ok {
+				return nil
+			}
+			fn(m.Payload)
+		}
+	}
+}
@@ -7,20 +7,67 @@ import (
 	"fmt"
 	"log/slog"
 	"net"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/redis/go-redis/v9"
 	"go.opentelemetry.io/otel/trace"
 )
 
-var ErrCacheMiss = errors.New("cache miss")
+var (
+	ErrCacheMiss = errors.New("cache miss")
+	// ErrUnavailable is returned by SetNX while Redis is degraded: a
+	// reservation cannot be made without Redis.
+	ErrUnavailable = errors.New("redis is unavailable")
+)
 
 type Redis struct {
-	Client *redis.Client
-	tr     trace.Tracer
+	Client   *redis.Client
+	tr       trace.Tracer
+	softFail bool
+	degraded atomic.Bool
+	onState  func(degraded bool)
+
+	// mu guards the keys written while degraded and leaving degraded mode,
+	// so that no such key is missed by recover.
+	mu       sync.Mutex
+	stale    map[string]struct{}
+	overflow bool
+	wake     chan struct{}
+	keep     []string
+}
+
+type Option func(*Redis)
+
+// WithSoftFail makes New return a degraded client instead of an error when
+// Redis cannot be reached. A degraded client acts as an empty cache until a
+// health check succeeds.
+func WithSoftFail() Option {
+	return func(r *Redis) {
+		r.softFail = true
+	}
 }
 
-func New(ctx context.Context, host, port, password string, db int, tr trace.Tracer) (*Redis, error) {
+// WithKeepOnPurge protects keys starting with one of prefixes when too many
+// keys changed during an outage and every other key is deleted on recovery.
+// Use it for keys that are not a cache of the database, such as idempotency
+// reservations.
+func WithKeepOnPurge(prefixes ...string) Option {
+	return func(r *Redis) {
+		r.keep = append(r.keep, prefixes...)
+	}
+}
+
+// WithStateHook calls fn whenever the client enters or leaves degraded mode,
+// and once from New with the initial state.
+func WithStateHook(fn func(degraded bool)) Option {
+	return func(r *Redis) {
+		r.onState = fn
+	}
+}
+
+func New(ctx context.Context, host, port, password string, db int, tr trace.Tracer, opts ...Option) (*Redis, error) {
 	const op = "repository.redis.New"
 
 	log := slog.With("op", op)
@@ -33,32 +80,73 @@ func New(ctx context.Context, host, port, password string, db int, tr trace.Trac
 		DB:       db,
 	})
 
+	r := &Redis{
+		Client: client,
+		tr:     tr,
+		stale:  make(map[string]struct{}),
+		wake:   make(chan struct{}, 1),
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
 	if err := client.Ping(ctx).Err(); err != nil {
 		log.Error("failed to connect to redis",
 			slog.String("op", op),
 			slog.String("addr", addr),
 			slog.Any("error", err),
 		)
-		_ = client.Close()
-		return nil, fmt.Errorf("%s: %w", op, err)
+		if !r.softFail {
+			_ = client.Close()
+			return nil, fmt.Errorf("%s: %w", op, err)
+		}
+		log.Warn("starting without redis, cache is degraded", slog.String("addr", addr))
+		r.degraded.Store(true)
+	} else {
+		slog.Info("Redis connected successfully", slog.String("addr", addr))
 	}
 
-	slog.Info("Redis connected successfully", slog.String("addr", addr))
-
-	return &Redis{Client: client, tr: tr}, nil
+	if r.onState != nil {
+		r.onState(r.Degraded())
+	}
+	return r, nil
 }
 
 func (r *Redis) Set(ctx context.Context, key string, value any, ttl time.Duration) error {
+	if r.skip(key) {
+		return nil
+	}
 	ctx, span := r.tr.Start(ctx, "redis.Set")
 	defer span.End()
 	data, err := json.Marshal(value)
 	if err != nil {
 		return fmt.Errorf("failed to marshal value for key %s: %w", key, err)
 	}
-	return r.Client.Set(ctx, key, data, ttl).Err()
+	return r.check(r.Client.Set(ctx, key, data, ttl).Err(), key)
+}
+
+// SetNX stores value only if key does not exist yet and reports whether it
+// was stored. It returns ErrUnavailable while Redis is degraded.
+func (r *Redis) SetNX(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	if r.Degraded() {
+		r.probe()
+		return false, ErrUnavailable
+	}
+	ctx, span := r.tr.Start(ctx, "redis.SetNX")
+	defer span.End()
+	data, err := json.Marshal(value)
+	if err != nil {
+		return false, fmt.Errorf("failed to marshal value for key %s: %w", key, err)
+	}
+	ok, err := r.Client.SetNX(ctx, key, data, ttl).Result()
+	return ok, r.check(err, key)
 }
 
 func (r *Redis) Get(ctx context.Context, key string, dest any) error {
+	if r.Degraded() {
+		r.probe()
+		return ErrCacheMiss
+	}
 	ctx, span := r.tr.Start(ctx, "redis.Get")
 	defer span.End()
 	data, err := r.Client.Get(ctx, key).Bytes()
@@ -66,13 +154,18 @@ func (r *Redis) Get(ctx context.Context, key string, dest any) error {
 		if errors.Is(err, redis.Nil) {
 			return ErrCacheMiss
 		}
-		return fmt.Errorf("failed to get key %s: %w", key, err)
+		return fmt.Errorf("failed to get key %s: %w", key, r.check(err))
 	}
 	return json.Unmarshal(data, dest)
 }
 
 func (r *Redis) Delete(ctx context.Context, key string) error {
-	return r.Client.Del(ctx, key).Err()
+	if r.skip(key) {
+		return nil
+	}
+	ctx, span := r.tr.Start(ctx, "redis.Delete")
+	defer span.End()
+	return r.check(r.Client.Del(ctx, key).Err(), key)
 }
 
 func (r *Redis) Close() error {
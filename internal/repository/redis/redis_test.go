@@ -87,4 +87,69 @@ func TestRedisRepository(t *testing.T) {
 		err = r.Get(ctx, key, &result)
 		assert.ErrorIs(t, err, ErrCacheMiss)
 	})
+	t.Run("GetWithTTL returns remaining TTL", func(t *testing.T) {
+		require.NoError(t, r.Set(ctx, "ttl-key", "v", time.Minute))
+
+		var result string
+		ttl, err := r.GetWithTTL(ctx, "ttl-key", &result)
+		require.NoError(t, err)
+		assert.Equal(t, "v", result)
+		assert.InDelta(t, time.Minute, ttl, float64(5*time.Second))
+
+		_, err = r.GetWithTTL(ctx, "missing-ttl-key", &result)
+		assert.ErrorIs(t, err, ErrCacheMiss)
+	})
+
+	t.Run("Keys written while degraded are deleted on recovery", func(t *testing.T) {
+		require.NoError(t, r.Set(ctx, "outage:deleted", "old", time.Hour))
+		require.NoError(t, r.Set(ctx, "outage:updated", "old", time.Hour))
+
+		r.setDegraded(true)
+		require.NoError(t, r.Delete(ctx, "outage:deleted"))
+		require.NoError(t, r.Set(ctx, "outage:updated", "new", time.Hour))
+		_, err := r.SetNX(ctx, "outage:reserved", "pending", time.Minute)
+		assert.ErrorIs(t, err, ErrUnavailable)
+		assert.Equal(t, int64(2), r.Client.Exists(ctx, "outage:deleted", "outage:updated").Val())
+
+		require.NoError(t, r.CheckHealth(ctx, time.Second))
+		assert.False(t, r.Degraded())
+		assert.Zero(t, r.Client.Exists(ctx, "outage:deleted", "outage:updated").Val())
+	})
+
+	t.Run("Overflowed purge keeps protected keys", func(t *testing.T) {
+		r.keep = []string{"keep:"}
+		defer func() { r.keep = nil }()
+		require.NoError(t, r.Set(ctx, "keep:reservation", "pending", time.Hour))
+		require.NoError(t, r.Set(ctx, "cached", "old", time.Hour))
+
+		r.setDegraded(true)
+		r.mu.Lock()
+		r.overflow = true
+		r.mu.Unlock()
+
+		require.NoError(t, r.CheckHealth(ctx, time.Second))
+		assert.False(t, r.Degraded())
+		assert.Equal(t, int64(1), r.Client.Exists(ctx, "keep:reservation").Val())
+		assert.Zero(t, r.Client.Exists(ctx, "cached").Val())
+	})
+
+	t.Run("Invalidations reach subscribers", func(t *testing.T) {
+		subCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+
+		got := make(chan string, 1)
+		go func() {
+			_ = r.SubscribeInvalidations(subCtx, func(msg string) { got <- msg })
+		}()
+
+		require.Eventually(t, func() bool {
+			require.NoError(t, r.PublishInvalidation(ctx, "origin key"))
+			select {
+			case msg := <-got:
+				return msg == "origin key"
+			default:
+				return false
+			}
+		}, 5*time.Second, 50*time.Millisecond)
+	})
 }
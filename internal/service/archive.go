@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// ArchiveOrders moves orders created more than retention ago to the archive
+// in batches of batchSize and returns the number of archived orders.
+func (s *OrderService) ArchiveOrders(ctx context.Context, retention time.Duration, batchSize int) (int, error) {
+	const op = "service.ArchiveOrders"
+
+	ctx, span := s.tr.Start(ctx, "service.ArchiveOrders")
+	defer span.End()
+
+	archived, err := s.archiveBefore(ctx, time.Now().Add(-retention), batchSize)
+	span.SetAttributes(attribute.Int("orders", archived))
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return archived, fmt.Errorf("%s: %w", op, err)
+	}
+	return archived, nil
+}
+
+// archiveBefore archives every order created before the given time in batches
+// of batchSize.
+func (s *OrderService) archiveBefore(ctx context.Context, before time.Time, batchSize int) (int, error) {
+	archived := 0
+	for {
+		uids, err := s.repo.ArchiveOrders(ctx, before, batchSize)
+		if err != nil {
+			return archived, fmt.Errorf("archived %d orders: %w", archived, err)
+		}
+
+		// Cached copies do not carry the archive marker.
+		for _, uid := range uids {
+			s.deleteCache(ctx, uid)
+		}
+		s.m.OrdersArchived.Add(float64(len(uids)))
+		archived += len(uids)
+
+		if len(uids) < batchSize || ctx.Err() != nil {
+			return archived, nil
+		}
+	}
+}
+
+// RunArchiver archives old orders every interval until ctx is cancelled.
+func (s *OrderService) RunArchiver(ctx context.Context, retention, interval time.Duration, batchSize int) error {
+	s.l.Info("archiver started", "retention", retention.String(), "interval", interval.String())
+	defer s.l.Info("archiver stopped")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		archived, err := s.ArchiveOrders(ctx, retention, batchSize)
+		if err != nil && ctx.Err() == nil {
+			s.l.Error("failed to archive orders", "error", err)
+		}
+		if archived > 0 {
+			s.l.Info("orders archived", "orders", archived)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,74 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// EraseOrder removes the personal data of an order, records who requested it
+// and evicts the order and its lookup keys from the cache.
+func (s *OrderService) EraseOrder(ctx context.Context, e *model.Erasure) error {
+	const op = "service.EraseOrder"
+
+	ctx, span := s.tr.Start(ctx, "service.EraseOrder")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("order_uid", e.OrderUID),
+		attribute.String("mode", string(e.Mode)),
+	)
+
+	if !e.Mode.Valid() {
+		return fmt.Errorf("%w: unknown mode %q", model.ErrInvalidErasure, e.Mode)
+	}
+	if e.RequestedBy == "" {
+		return fmt.Errorf("%w: requested_by is required", model.ErrInvalidErasure)
+	}
+
+	order, err := s.repo.GetOrder(ctx, e.OrderUID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return model.ErrNotFound
+		}
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if err := s.repo.EraseOrder(ctx, e); err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return model.ErrNotFound
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.m.OrderErasures.WithLabelValues(string(e.Mode)).Inc()
+	s.l.Info("order erased", "uid", e.OrderUID, "mode", e.Mode, "requested_by", e.RequestedBy)
+
+	s.deleteCache(ctx, order.OrderUID)
+	s.deleteCache(ctx, trackKeyPrefix+order.TrackNumber)
+	s.deleteCache(ctx, transactionKeyPrefix+order.Payment.Transaction)
+	for _, item := range order.Items {
+		s.deleteCache(ctx, ridKeyPrefix+item.Rid)
+	}
+	return nil
+}
+
+func (s *OrderService) ListErasures(ctx context.Context, orderUID string) ([]model.Erasure, error) {
+	const op = "service.ListErasures"
+
+	ctx, span := s.tr.Start(ctx, "service.ListErasures")
+	defer span.End()
+
+	erasures, err := s.repo.ListErasures(ctx, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(erasures) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return erasures, nil
+}
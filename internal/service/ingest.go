@@ -0,0 +1,222 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const ingestReplay = "replay"
+
+// IngestOrder saves an order read from Kafka and records the message in the
+// ingestion ledger in the same transaction. A message that is already in the
+// ledger with the same payload is a replay and is skipped.
+func (s *OrderService) IngestOrder(ctx context.Context, order *model.Order, src model.MessageSource) error {
+	const op = "service.IngestOrder"
+
+	ctx, span := s.tr.Start(ctx, "service.IngestOrder")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("order_uid", order.OrderUID),
+		attribute.String("topic", src.Topic),
+		attribute.Int("partition", src.Partition),
+		attribute.Int64("offset", src.Offset),
+	)
+
+	replayed, err := s.replayed(ctx, order, src)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if replayed {
+		return nil
+	}
+
+	s.resetStatus(order)
+	res, err := s.repo.IngestOrder(ctx, order, src, s.policy)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	span.SetAttributes(attribute.String("save_status", string(res.Status)))
+	s.m.IngestedMessages.WithLabelValues(string(res.Status)).Inc()
+
+	if err := s.saved(ctx, order, res); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// IngestOrders saves a batch of orders read from Kafka in one transaction;
+// srcs[i] is the message of orders[i]. Replayed messages are skipped and
+// reported as duplicates.
+func (s *OrderService) IngestOrders(ctx context.Context, orders []*model.Order, srcs []model.MessageSource) ([]model.SaveResult, error) {
+	const op = "service.IngestOrders"
+
+	ctx, span := s.tr.Start(ctx, "service.IngestOrders")
+	defer span.End()
+	span.SetAttributes(attribute.Int("orders", len(orders)))
+
+	records, err := s.repo.GetIngestions(ctx, srcs)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	seen := make(map[offsetKey]*model.IngestionRecord, len(records))
+	for i := range records {
+		rec := &records[i]
+		seen[offsetKey{rec.Topic, rec.Partition, rec.Offset}] = rec
+	}
+
+	results := make([]model.SaveResult, len(orders))
+	pending := make([]*model.Order, 0, len(orders))
+	pendingSrcs := make([]model.MessageSource, 0, len(orders))
+	idx := make([]int, 0, len(orders))
+	for i, order := range orders {
+		results[i] = model.SaveResult{OrderUID: order.OrderUID, Status: model.SaveDuplicate}
+
+		src := srcs[i]
+		if s.isReplay(order, src, seen[offsetKey{src.Topic, src.Partition, src.Offset}]) {
+			continue
+		}
+		s.resetStatus(order)
+		pending = append(pending, order)
+		pendingSrcs = append(pendingSrcs, srcs[i])
+		idx = append(idx, i)
+	}
+	if len(pending) == 0 {
+		return results, nil
+	}
+
+	saved, err := s.repo.IngestOrders(ctx, pending, pendingSrcs, s.policy)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for j, res := range saved {
+		i := idx[j]
+		results[i] = res
+		if res.Err != nil {
+			continue
+		}
+		s.m.IngestedMessages.WithLabelValues(string(res.Status)).Inc()
+		if err := s.saved(ctx, pending[j], res); err != nil {
+			results[i].Err = err
+		}
+	}
+	return results, nil
+}
+
+// offsetKey identifies a Kafka message in the ledger.
+type offsetKey struct {
+	topic     string
+	partition int
+	offset    int64
+}
+
+// replayed reports whether the message is already in the ledger with the same
+// payload.
+func (s *OrderService) replayed(ctx context.Context, order *model.Order, src model.MessageSource) (bool, error) {
+	prev, err := s.repo.GetIngestion(ctx, src.Topic, src.Partition, src.Offset)
+	if err != nil && !errors.Is(err, model.ErrNotFound) {
+		return false, err
+	}
+	return s.isReplay(order, src, prev), nil
+}
+
+// isReplay reports whether prev, the ledger entry of the message if any, has
+// the same payload.
+func (s *OrderService) isReplay(order *model.Order, src model.MessageSource, prev *model.IngestionRecord) bool {
+	switch {
+	case prev == nil:
+		return false
+	case prev.PayloadHash == src.PayloadHash:
+		s.m.IngestedMessages.WithLabelValues(ingestReplay).Inc()
+		s.l.Debug("message already ingested", "uid", order.OrderUID, "offset", src.Offset)
+		return true
+	default:
+		s.l.Warn("ingested offset seen again with a different payload",
+			"uid", order.OrderUID, "previous_uid", prev.OrderUID, "offset", src.Offset)
+		return false
+	}
+}
+
+func (s *OrderService) ListIngestions(ctx context.Context, orderUID string) ([]model.IngestionRecord, error) {
+	const op = "service.ListIngestions"
+
+	ctx, span := s.tr.Start(ctx, "service.ListIngestions")
+	defer span.End()
+
+	records, err := s.repo.ListIngestions(ctx, orderUID)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	if len(records) == 0 {
+		return nil, model.ErrNotFound
+	}
+	return records, nil
+}
+
+// PurgeIngestions removes ledger entries older than retention in batches of
+// batchSize and returns the number of removed entries.
+func (s *OrderService) PurgeIngestions(ctx context.Context, retention time.Duration, batchSize int) (int64, error) {
+	const op = "service.PurgeIngestions"
+
+	ctx, span := s.tr.Start(ctx, "service.PurgeIngestions")
+	defer span.End()
+
+	before := time.Now().Add(-retention)
+	var purged int64
+	for {
+		n, err := s.repo.DeleteIngestions(ctx, before, batchSize)
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return purged, fmt.Errorf("%s: purged %d entries: %w", op, purged, err)
+		}
+		s.m.IngestionsPurged.Add(float64(n))
+		purged += n
+
+		if n < int64(batchSize) || ctx.Err() != nil {
+			break
+		}
+	}
+	span.SetAttributes(attribute.Int64("entries", purged))
+	return purged, nil
+}
+
+// RunLedgerCleanup purges old ledger entries every interval until ctx is
+// cancelled.
+func (s *OrderService) RunLedgerCleanup(ctx context.Context, retention, interval time.Duration, batchSize int) error {
+	s.l.Info("ledger cleanup started", "retention", retention.String(), "interval", interval.String())
+	defer s.l.Info("ledger cleanup stopped")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := s.PurgeIngestions(ctx, retention, batchSize)
+		if err != nil && ctx.Err() == nil {
+			s.l.Error("failed to purge ingestion ledger", "error", err)
+		}
+		if purged > 0 {
+			s.l.Info("ingestion ledger purged", "entries", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
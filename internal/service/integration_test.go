@@ -21,6 +21,9 @@ import (
 	"github.com/MikebangSfilya/wb/internal/service"
 	"github.com/MikebangSfilya/wb/internal/transport/handlers"
 	"github.com/go-chi/chi/v5"
+	"github.com/golang-migrate/migrate/v4"
+	_ "github.com/golang-migrate/migrate/v4/database/postgres"
+	_ "github.com/golang-migrate/migrate/v4/source/file"
 	"github.com/jackc/pgx/v5/pgxpool"
 
 	"github.com/stretchr/testify/assert"
@@ -52,12 +55,14 @@ func Test_Integration(t *testing.T) {
 	connectionString, err := postgresContainer.ConnectionString(ctx, "sslmode=disable")
 	require.NoError(t, err)
 
-	pool, err := pgxpool.New(ctx, connectionString)
+	m, err := migrate.New("file://../../db/migrations", connectionString)
 	require.NoError(t, err)
-	defer pool.Close()
+	require.NoError(t, m.Up())
+	_, _ = m.Close()
 
-	_, err = pool.Exec(ctx, initSQL)
+	pool, err := pgxpool.New(ctx, connectionString)
 	require.NoError(t, err)
+	defer pool.Close()
 
 	redisContainer, err := redisMod.Run(ctx, "redis:7-alpine")
 	require.NoError(t, err)
@@ -133,7 +138,7 @@ func Test_Integration(t *testing.T) {
 		},
 	}
 
-	err = svc.CreateOrder(ctx, order)
+	_, err = svc.CreateOrder(ctx, order)
 	require.NoError(t, err)
 
 	resp, err := http.Get(fmt.Sprintf("%s/order/%s", ts.URL, order.OrderUID))
@@ -153,57 +158,3 @@ func Test_Integration(t *testing.T) {
 	assert.Equal(t, order.Payment.Amount, retrieved.Payment.Amount)
 	assert.True(t, order.DateCreated.Equal(retrieved.DateCreated))
 }
-
-const initSQL = `
-CREATE TABLE IF NOT EXISTS orders (
-    order_uid TEXT PRIMARY KEY,
-    track_number TEXT NOT NULL,
-    entry TEXT NOT NULL,
-    locale TEXT NOT NULL,
-    internal_signature TEXT,
-    customer_id TEXT NOT NULL,
-    delivery_service TEXT NOT NULL,
-    shardkey TEXT NOT NULL,
-    sm_id INTEGER NOT NULL,
-    date_created TIMESTAMPTZ NOT NULL,
-    oof_shard TEXT NOT NULL
-);
-CREATE TABLE IF NOT EXISTS delivery (
-    order_uid TEXT PRIMARY KEY REFERENCES orders(order_uid) ON DELETE CASCADE,
-    name TEXT NOT NULL,
-    phone TEXT NOT NULL,
-    zip TEXT NOT NULL,
-    city TEXT NOT NULL,
-    address TEXT NOT NULL,
-    region TEXT NOT NULL,
-    email TEXT NOT NULL
-);
-CREATE TABLE IF NOT EXISTS payment (
-    transaction TEXT PRIMARY KEY,
-    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
-    request_id TEXT,
-    currency TEXT NOT NULL,
-    provider TEXT NOT NULL,
-    amount INTEGER NOT NULL,
-    payment_dt BIGINT NOT NULL,
-    bank TEXT NOT NULL,
-    delivery_cost INTEGER NOT NULL,
-    goods_total INTEGER NOT NULL,
-    custom_fee INTEGER NOT NULL DEFAULT 0
-);
-CREATE TABLE IF NOT EXISTS items (
-    id SERIAL PRIMARY KEY,
-    order_uid TEXT NOT NULL REFERENCES orders(order_uid) ON DELETE CASCADE,
-    chrt_id INTEGER NOT NULL,
-    track_number TEXT NOT NULL,
-    price INTEGER NOT NULL,
-    rid TEXT NOT NULL,
-    name TEXT NOT NULL,
-    sale INTEGER NOT NULL,
-    size TEXT NOT NULL,
-    total_price INTEGER NOT NULL,
-    nm_id INTEGER NOT NULL,
-    brand TEXT NOT NULL,
-    status INTEGER NOT NULL
-);
-`
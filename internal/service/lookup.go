@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+const (
+	trackKeyPrefix       = "track:"
+	transactionKeyPrefix = "tx:"
+	ridKeyPrefix         = "rid:"
+)
+
+func (s *OrderService) GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*model.Order, error) {
+	return s.getOrderBy(ctx, trackKeyPrefix, trackNumber, s.repo.GetOrderUIDByTrackNumber)
+}
+
+func (s *OrderService) GetOrderByTransaction(ctx context.Context, transaction string) (*model.Order, error) {
+	return s.getOrderBy(ctx, transactionKeyPrefix, transaction, s.repo.GetOrderUIDByTransaction)
+}
+
+func (s *OrderService) GetOrderByRid(ctx context.Context, rid string) (*model.Order, error) {
+	return s.getOrderBy(ctx, ridKeyPrefix, rid, s.repo.GetOrderUIDByRid)
+}
+
+func (s *OrderService) getOrderBy(
+	ctx context.Context,
+	prefix, value string,
+	resolve func(ctx context.Context, value string) (string, error),
+) (*model.Order, error) {
+	const op = "service.getOrderBy"
+
+	ctx, span := s.tr.Start(ctx, "service.GetOrderBy")
+	defer span.End()
+	span.SetAttributes(attribute.String("key", prefix+value))
+
+	var orderUID string
+
+	cacheCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	err := s.cache.Get(cacheCtx, prefix+value, &orderUID)
+	cancel()
+	if err == nil {
+		order, err := s.GetOrder(ctx, orderUID)
+		if !errors.Is(err, model.ErrNotFound) {
+			return order, err
+		}
+		s.l.Debug("stale secondary key", "key", prefix+value, "uid", orderUID)
+	} else if !errors.Is(err, redis.ErrCacheMiss) {
+		s.l.Error("service: cache error", "error", err)
+	}
+
+	orderUID, err = resolve(ctx, value)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, model.ErrNotFound
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	s.setCache(ctx, prefix+value, orderUID, 24*time.Hour)
+
+	return s.GetOrder(ctx, orderUID)
+}
+
+func (s *OrderService) setSecondaryKeys(ctx context.Context, order *model.Order, ttl time.Duration) {
+	s.setCache(ctx, trackKeyPrefix+order.TrackNumber, order.OrderUID, ttl)
+	s.setCache(ctx, transactionKeyPrefix+order.Payment.Transaction, order.OrderUID, ttl)
+	for _, item := range order.Items {
+		s.setCache(ctx, ridKeyPrefix+item.Rid, order.OrderUID, ttl)
+	}
+}
+
+// deleteStaleKeys removes the lookup keys an order no longer has after it was
+// replaced.
+func (s *OrderService) deleteStaleKeys(ctx context.Context, prev, cur model.SecondaryKeys) {
+	if prev.TrackNumber != cur.TrackNumber {
+		s.deleteCache(ctx, trackKeyPrefix+prev.TrackNumber)
+	}
+	if prev.Transaction != cur.Transaction {
+		s.deleteCache(ctx, transactionKeyPrefix+prev.Transaction)
+	}
+	for _, rid := range prev.Rids {
+		if !slices.Contains(cur.Rids, rid) {
+			s.deleteCache(ctx, ridKeyPrefix+rid)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+)
+
+const partitionArchiveBatch = 1000
+
+// MaintainPartitions creates the order table partitions for the current and
+// the next premakeMonths months. When retentionMonths is positive, partitions
+// that ended more than retentionMonths months ago are dropped after their
+// orders are moved to the archive.
+func (s *OrderService) MaintainPartitions(ctx context.Context, premakeMonths, retentionMonths int) error {
+	const op = "service.MaintainPartitions"
+
+	ctx, span := s.tr.Start(ctx, "service.MaintainPartitions")
+	defer span.End()
+
+	now := time.Now().UTC()
+	if err := s.repo.CreatePartitions(ctx, now, now.AddDate(0, premakeMonths, 0)); err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	if retentionMonths <= 0 {
+		return nil
+	}
+	before := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -retentionMonths, 0)
+	archived, err := s.archiveBefore(ctx, before, partitionArchiveBatch)
+	if archived > 0 {
+		s.l.Info("orders archived before dropping partitions", "orders", archived)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	dropped, err := s.repo.DropPartitions(ctx, before)
+	if len(dropped) > 0 {
+		s.l.Info("order partitions dropped", "months", dropped)
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	return nil
+}
+
+// RunPartitionMaintenance calls MaintainPartitions every interval until ctx
+// is cancelled.
+func (s *OrderService) RunPartitionMaintenance(ctx context.Context, premakeMonths, retentionMonths int, interval time.Duration) error {
+	s.l.Info("partition maintenance started", "premake_months", premakeMonths, "retention_months", retentionMonths)
+	defer s.l.Info("partition maintenance stopped")
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.MaintainPartitions(ctx, premakeMonths, retentionMonths); err != nil && ctx.Err() == nil {
+			s.l.Error("failed to maintain order partitions", "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
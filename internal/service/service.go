@@ -13,48 +13,169 @@ import (
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/codes"
 	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/sync/singleflight"
 )
 
 type Repository interface {
-	CreateOrder(ctx context.Context, order *model.Order) error
+	CreateOrder(ctx context.Context, order *model.Order, policy model.ConflictPolicy) (model.SaveResult, error)
+	CreateOrders(ctx context.Context, orders []*model.Order, policy model.ConflictPolicy) ([]model.SaveResult, error)
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	GetOrderUIDByTrackNumber(ctx context.Context, trackNumber string) (string, error)
+	GetOrderUIDByTransaction(ctx context.Context, transaction string) (string, error)
+	GetOrderUIDByRid(ctx context.Context, rid string) (string, error)
+	GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error)
+	UpdateOrderStatus(ctx context.Context, change *model.StatusChange) error
+	StatusEventApplied(ctx context.Context, eventID string) (bool, error)
+	GetIngestion(ctx context.Context, topic string, partition int, offset int64) (*model.IngestionRecord, error)
+	GetIngestions(ctx context.Context, srcs []model.MessageSource) ([]model.IngestionRecord, error)
+	IngestOrder(ctx context.Context, order *model.Order, src model.MessageSource, policy model.ConflictPolicy) (model.SaveResult, error)
+	IngestOrders(ctx context.Context, orders []*model.Order, srcs []model.MessageSource, policy model.ConflictPolicy) ([]model.SaveResult, error)
+	DeleteIngestions(ctx context.Context, before time.Time, limit int) (int64, error)
+	ListIngestions(ctx context.Context, orderUID string) ([]model.IngestionRecord, error)
+	GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error)
+	EraseOrder(ctx context.Context, e *model.Erasure) error
+	ListErasures(ctx context.Context, orderUID string) ([]model.Erasure, error)
+	ArchiveOrders(ctx context.Context, before time.Time, limit int) ([]string, error)
+	CreatePartitions(ctx context.Context, from, to time.Time) error
+	DropPartitions(ctx context.Context, before time.Time) ([]string, error)
 }
 
 type Cache interface {
 	Set(ctx context.Context, key string, value any, ttl time.Duration) error
 	Get(ctx context.Context, key string, dest any) error
+	Delete(ctx context.Context, key string) error
 }
 
+const (
+	loadTimeout       = 5 * time.Second
+	notFoundKeyPrefix = "notfound:"
+)
+
 type OrderService struct {
-	repo  Repository
-	cache Cache
-	l     *slog.Logger
-	tr    trace.Tracer
-	m     *metrics.Metrics
+	repo        Repository
+	cache       Cache
+	l           *slog.Logger
+	tr          trace.Tracer
+	m           *metrics.Metrics
+	loads       singleflight.Group
+	negativeTTL time.Duration
+	policy      model.ConflictPolicy
+}
+
+type Option func(*OrderService)
+
+// WithConflictPolicy sets how re-sent orders with an existing order_uid are
+// handled. The default is model.ConflictIgnore.
+func WithConflictPolicy(policy model.ConflictPolicy) Option {
+	return func(s *OrderService) {
+		s.policy = policy
+	}
 }
 
-func New(l *slog.Logger, repo Repository, cache Cache, m *metrics.Metrics, tr trace.Tracer) *OrderService {
-	return &OrderService{
-		repo:  repo,
-		cache: cache,
-		l:     l,
-		tr:    tr,
-		m:     m,
+func WithNegativeTTL(ttl time.Duration) Option {
+	return func(s *OrderService) {
+		s.negativeTTL = ttl
 	}
 }
 
-func (s *OrderService) CreateOrder(ctx context.Context, order *model.Order) error {
+func New(l *slog.Logger, repo Repository, cache Cache, m *metrics.Metrics, tr trace.Tracer, opts ...Option) *OrderService {
+	s := &OrderService{
+		repo:   repo,
+		cache:  cache,
+		l:      l,
+		tr:     tr,
+		m:      m,
+		policy: model.ConflictIgnore,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateOrder saves the order and reports what happened to the stored copy.
+func (s *OrderService) CreateOrder(ctx context.Context, order *model.Order) (model.SaveStatus, error) {
 	const op = "service.CreateOrder"
 	ctx, span := s.tr.Start(ctx, "service.CreateOrder")
 	defer span.End()
 	span.SetAttributes(attribute.String("order_uid", order.OrderUID))
-	if err := s.repo.CreateOrder(ctx, order); err != nil {
+	s.resetStatus(order)
+	res, err := s.repo.CreateOrder(ctx, order, s.policy)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return model.SaveFailed, fmt.Errorf("%s: %w", op, err)
+	}
+	span.SetAttributes(attribute.String("save_status", string(res.Status)))
+	if err := s.saved(ctx, order, res); err != nil {
+		return res.Status, fmt.Errorf("%s: %w", op, err)
+	}
+	return res.Status, nil
+}
+
+func (s *OrderService) CreateOrders(ctx context.Context, orders []*model.Order) ([]model.SaveResult, error) {
+	const op = "service.CreateOrders"
+	ctx, span := s.tr.Start(ctx, "service.CreateOrders")
+	defer span.End()
+	span.SetAttributes(attribute.Int("orders", len(orders)))
+	for _, order := range orders {
+		s.resetStatus(order)
+	}
+
+	results, err := s.repo.CreateOrders(ctx, orders, s.policy)
+	if err != nil {
 		span.RecordError(err)
 		span.SetStatus(codes.Error, err.Error())
-		return fmt.Errorf("%s: %w", op, err)
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+
+	for i, res := range results {
+		if res.Err != nil {
+			continue
+		}
+		if err := s.saved(ctx, orders[i], res); err != nil {
+			results[i].Err = err
+		}
+	}
+	return results, nil
+}
+
+// resetStatus starts every incoming order as created. Later statuses only
+// come through UpdateOrderStatus, so a status sent with the order cannot skip
+// the transition table.
+func (s *OrderService) resetStatus(order *model.Order) {
+	if order.Status != "" && order.Status != model.StatusCreated {
+		s.l.Warn("ignoring status of incoming order", "uid", order.OrderUID, "status", order.Status)
+	}
+	order.Status = model.StatusCreated
+}
+
+// saved updates metrics and the cache after a save. The cache is only
+// touched when the database actually changed.
+func (s *OrderService) saved(ctx context.Context, order *model.Order, res model.SaveResult) error {
+	status := res.Status
+	s.m.OrderSaves.WithLabelValues(string(status)).Inc()
+
+	switch status {
+	case model.SaveInserted:
+		s.m.OrdersCreated.Inc()
+		s.forgetNotFound(ctx, order.OrderUID)
+	case model.SaveIgnored:
+		s.l.Warn("order re-sent with different content, ignored", "uid", order.OrderUID)
+	case model.SaveErased:
+		s.l.Warn("erased order re-sent, dropped", "uid", order.OrderUID)
+	case model.SaveConflict:
+		return model.ErrOrderConflict
+	}
+
+	if status.Changed() {
+		if res.Previous != nil {
+			s.deleteStaleKeys(ctx, *res.Previous, order.SecondaryKeys())
+		}
+		s.setCache(ctx, order.OrderUID, order, 24*time.Hour)
+		s.setSecondaryKeys(ctx, order, 24*time.Hour)
 	}
-	s.m.OrdersCreated.Inc()
-	s.setCache(ctx, order.OrderUID, order, 24*time.Hour)
 	return nil
 }
 
@@ -83,18 +204,123 @@ func (s *OrderService) GetOrder(ctx context.Context, orderUID string) (*model.Or
 		s.l.Error("service: cache error", "error", err)
 	}
 
-	orderPtr, err := s.repo.GetOrder(ctx, orderUID)
+	orderPtr, err := s.loadOrder(ctx, orderUID)
 	if err != nil {
 		if errors.Is(err, model.ErrNotFound) {
 			return nil, model.ErrNotFound
 		}
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
-	s.setCache(ctx, orderPtr.OrderUID, orderPtr, 24*time.Hour)
 
 	return orderPtr, nil
 }
 
+func (s *OrderService) loadOrder(ctx context.Context, orderUID string) (*model.Order, error) {
+	leader := false
+	ch := s.loads.DoChan(orderUID, func() (any, error) {
+		leader = true
+
+		loadCtx, cancel := context.WithTimeout(context.WithoutCancel(ctx), loadTimeout)
+		defer cancel()
+
+		if s.knownNotFound(loadCtx, orderUID) {
+			s.m.NegativeCacheHits.Inc()
+			return nil, model.ErrNotFound
+		}
+
+		order, err := s.repo.GetOrder(loadCtx, orderUID)
+		if errors.Is(err, model.ErrNotFound) && s.negativeTTL > 0 {
+			order, err = s.cacheNotFound(loadCtx, orderUID)
+		}
+		if err != nil {
+			return nil, err
+		}
+		s.setCache(loadCtx, order.OrderUID, order, 24*time.Hour)
+		return order, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-ch:
+		if !leader {
+			s.m.CoalescedLoads.Inc()
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+		// Every caller gets its own copy, so one of them changing the order
+		// does not affect the others.
+		return res.Val.(*model.Order).Clone(), nil
+	}
+}
+
+func (s *OrderService) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	const op = "service.ListOrders"
+
+	ctx, span := s.tr.Start(ctx, "service.ListOrders")
+	defer span.End()
+
+	page, err := s.repo.ListOrders(ctx, filter)
+	if err != nil {
+		if errors.Is(err, model.ErrInvalidCursor) {
+			return nil, err
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return page, nil
+}
+
+func (s *OrderService) knownNotFound(ctx context.Context, orderUID string) bool {
+	if s.negativeTTL <= 0 {
+		return false
+	}
+
+	cacheCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+	defer cancel()
+
+	var missing bool
+	err := s.cache.Get(cacheCtx, notFoundKeyPrefix+orderUID, &missing)
+	if err != nil && !errors.Is(err, redis.ErrCacheMiss) {
+		s.l.Error("service: cache error", "error", err)
+	}
+	return err == nil && missing
+}
+
+// cacheNotFound stores the negative entry and then reads the order again. An
+// order inserted after the first read may have run forgetNotFound before the
+// entry was written, so if the order shows up now the entry is removed here.
+func (s *OrderService) cacheNotFound(ctx context.Context, orderUID string) (*model.Order, error) {
+	s.setCache(ctx, notFoundKeyPrefix+orderUID, true, s.negativeTTL)
+
+	order, err := s.repo.GetOrder(ctx, orderUID)
+	if errors.Is(err, model.ErrNotFound) {
+		return nil, err
+	}
+	s.forgetNotFound(ctx, orderUID)
+	return order, err
+}
+
+func (s *OrderService) forgetNotFound(ctx context.Context, orderUID string) {
+	if s.negativeTTL <= 0 {
+		return
+	}
+	s.deleteCache(ctx, notFoundKeyPrefix+orderUID)
+}
+
+func (s *OrderService) deleteCache(ctx context.Context, key string) {
+	cacheCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
+	defer cancel()
+	if err := s.cache.Delete(cacheCtx, key); err != nil {
+		s.l.Error("cache delete failed",
+			slog.String("key", key),
+			slog.String("error", err.Error()),
+		)
+	}
+}
+
 func (s *OrderService) setCache(ctx context.Context, key string, value any, ttl time.Duration) {
 	cacheCtx, cancel := context.WithTimeout(ctx, 1*time.Second)
 	defer cancel()
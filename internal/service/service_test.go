@@ -5,11 +5,14 @@ import (
 	"errors"
 	"io"
 	"log/slog"
+	"sync"
 	"testing"
 	"time"
 
 	"github.com/MikebangSfilya/wb/internal/lib/metrics"
 	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"go.opentelemetry.io/otel/trace/noop"
@@ -19,9 +22,20 @@ type MockRepo struct {
 	mock.Mock
 }
 
-func (m *MockRepo) CreateOrder(ctx context.Context, order *model.Order) error {
-	args := m.Called(ctx, order)
-	return args.Error(0)
+func (m *MockRepo) CreateOrder(ctx context.Context, order *model.Order, policy model.ConflictPolicy) (model.SaveResult, error) {
+	args := m.Called(ctx, order, policy)
+	if res, ok := args.Get(0).(model.SaveResult); ok {
+		return res, args.Error(1)
+	}
+	return model.SaveResult{OrderUID: order.OrderUID, Status: args.Get(0).(model.SaveStatus)}, args.Error(1)
+}
+
+func (m *MockRepo) CreateOrders(ctx context.Context, orders []*model.Order, policy model.ConflictPolicy) ([]model.SaveResult, error) {
+	args := m.Called(ctx, orders, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SaveResult), args.Error(1)
 }
 
 func (m *MockRepo) GetOrder(ctx context.Context, orderUID string) (*model.Order, error) {
@@ -32,6 +46,128 @@ func (m *MockRepo) GetOrder(ctx context.Context, orderUID string) (*model.Order,
 	return args.Get(0).(*model.Order), args.Error(1)
 }
 
+func (m *MockRepo) ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error) {
+	args := m.Called(ctx, filter)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.OrderPage), args.Error(1)
+}
+
+func (m *MockRepo) GetOrderUIDByTrackNumber(ctx context.Context, trackNumber string) (string, error) {
+	args := m.Called(ctx, trackNumber)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepo) GetOrderUIDByTransaction(ctx context.Context, transaction string) (string, error) {
+	args := m.Called(ctx, transaction)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepo) GetOrderUIDByRid(ctx context.Context, rid string) (string, error) {
+	args := m.Called(ctx, rid)
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockRepo) GetOrderStatus(ctx context.Context, orderUID string) (model.OrderStatus, error) {
+	args := m.Called(ctx, orderUID)
+	return args.Get(0).(model.OrderStatus), args.Error(1)
+}
+
+func (m *MockRepo) UpdateOrderStatus(ctx context.Context, change *model.StatusChange) error {
+	args := m.Called(ctx, change)
+	return args.Error(0)
+}
+
+func (m *MockRepo) StatusEventApplied(ctx context.Context, eventID string) (bool, error) {
+	args := m.Called(ctx, eventID)
+	return args.Bool(0), args.Error(1)
+}
+
+func (m *MockRepo) GetIngestion(ctx context.Context, topic string, partition int, offset int64) (*model.IngestionRecord, error) {
+	args := m.Called(ctx, topic, partition, offset)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).(*model.IngestionRecord), args.Error(1)
+}
+
+func (m *MockRepo) GetIngestions(ctx context.Context, srcs []model.MessageSource) ([]model.IngestionRecord, error) {
+	args := m.Called(ctx, srcs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.IngestionRecord), args.Error(1)
+}
+
+func (m *MockRepo) IngestOrder(ctx context.Context, order *model.Order, src model.MessageSource, policy model.ConflictPolicy) (model.SaveResult, error) {
+	args := m.Called(ctx, order, src, policy)
+	return model.SaveResult{OrderUID: order.OrderUID, Status: args.Get(0).(model.SaveStatus)}, args.Error(1)
+}
+
+func (m *MockRepo) IngestOrders(ctx context.Context, orders []*model.Order, srcs []model.MessageSource, policy model.ConflictPolicy) ([]model.SaveResult, error) {
+	args := m.Called(ctx, orders, srcs, policy)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SaveResult), args.Error(1)
+}
+
+func (m *MockRepo) DeleteIngestions(ctx context.Context, before time.Time, limit int) (int64, error) {
+	args := m.Called(ctx, before, limit)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func (m *MockRepo) ListIngestions(ctx context.Context, orderUID string) ([]model.IngestionRecord, error) {
+	args := m.Called(ctx, orderUID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.IngestionRecord), args.Error(1)
+}
+
+func (m *MockRepo) GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	args := m.Called(ctx, orderUID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.StatusChange), args.Error(1)
+}
+
+func (m *MockRepo) EraseOrder(ctx context.Context, e *model.Erasure) error {
+	args := m.Called(ctx, e)
+	return args.Error(0)
+}
+
+func (m *MockRepo) ListErasures(ctx context.Context, orderUID string) ([]model.Erasure, error) {
+	args := m.Called(ctx, orderUID)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.Erasure), args.Error(1)
+}
+
+func (m *MockRepo) ArchiveOrders(ctx context.Context, before time.Time, limit int) ([]string, error) {
+	args := m.Called(ctx, before, limit)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
+func (m *MockRepo) CreatePartitions(ctx context.Context, from, to time.Time) error {
+	args := m.Called(ctx, from, to)
+	return args.Error(0)
+}
+
+func (m *MockRepo) DropPartitions(ctx context.Context, before time.Time) ([]string, error) {
+	args := m.Called(ctx, before)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]string), args.Error(1)
+}
+
 type MockCache struct {
 	mock.Mock
 }
@@ -46,6 +182,11 @@ func (m *MockCache) Get(ctx context.Context, key string, dest any) error {
 	return args.Error(0)
 }
 
+func (m *MockCache) Delete(ctx context.Context, key string) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
 func TestOrderService_CreateOrder(t *testing.T) {
 	type mockBehavior func(r *MockRepo, c *MockCache, order *model.Order)
 
@@ -59,7 +200,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			name:  "success",
 			order: &model.Order{OrderUID: "034"},
 			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
-				r.On("CreateOrder", mock.Anything, order).Return(nil)
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveInserted, nil)
 				c.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
 			},
 			wantErr: false,
@@ -68,7 +209,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			name:  "db_error",
 			order: &model.Order{OrderUID: "034"},
 			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
-				r.On("CreateOrder", mock.Anything, order).Return(errors.New("db error"))
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveFailed, errors.New("db error"))
 			},
 			wantErr: true,
 		},
@@ -76,11 +217,70 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			name:  "cache_error",
 			order: &model.Order{OrderUID: "034"},
 			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
-				r.On("CreateOrder", mock.Anything, order).Return(nil)
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveInserted, nil)
 				c.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(errors.New("cache error"))
 			},
 			wantErr: false,
 		},
+		{
+			name:  "duplicate_keeps_cache",
+			order: &model.Order{OrderUID: "034"},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveDuplicate, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:  "ignored_keeps_cache",
+			order: &model.Order{OrderUID: "034"},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveIgnored, nil)
+			},
+			wantErr: false,
+		},
+		{
+			name:  "rejected",
+			order: &model.Order{OrderUID: "034"},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveConflict, nil)
+			},
+			wantErr: true,
+		},
+		{
+			name:  "incoming_status_is_reset",
+			order: &model.Order{OrderUID: "034", Status: model.StatusDelivered},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				r.On("CreateOrder", mock.Anything, mock.MatchedBy(func(o *model.Order) bool {
+					return o.Status == model.StatusCreated
+				}), model.ConflictIgnore).Return(model.SaveInserted, nil)
+				c.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			wantErr: false,
+		},
+		{
+			name: "replaced_deletes_stale_keys",
+			order: &model.Order{
+				OrderUID:    "034",
+				TrackNumber: "NEWTRACK",
+				Payment:     model.Payment{Transaction: "tx-1"},
+				Items:       []model.Item{{Rid: "rid-1"}, {Rid: "rid-3"}},
+			},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				r.On("CreateOrder", mock.Anything, order, model.ConflictIgnore).Return(model.SaveResult{
+					OrderUID: "034",
+					Status:   model.SaveUpdated,
+					Previous: &model.SecondaryKeys{
+						TrackNumber: "OLDTRACK",
+						Transaction: "tx-1",
+						Rids:        []string{"rid-1", "rid-2"},
+					},
+				}, nil)
+				c.On("Delete", mock.Anything, "track:OLDTRACK").Return(nil).Once()
+				c.On("Delete", mock.Anything, "rid:rid-2").Return(nil).Once()
+				c.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			wantErr: false,
+		},
 	}
 
 	for _, tt := range tests {
@@ -93,7 +293,7 @@ func TestOrderService_CreateOrder(t *testing.T) {
 			testMetrics := metrics.NewTestMetrics()
 			testTracer := noop.NewTracerProvider().Tracer("test")
 			svc := New(logger, mockRepo, mockCache, testMetrics, testTracer)
-			err := svc.CreateOrder(context.Background(), tt.order)
+			_, err := svc.CreateOrder(context.Background(), tt.order)
 			if !tt.wantErr {
 				assert.NoError(t, err)
 			} else {
@@ -106,6 +306,32 @@ func TestOrderService_CreateOrder(t *testing.T) {
 	}
 }
 
+func TestOrderService_CreateOrders(t *testing.T) {
+	t.Parallel()
+	mockRepo := &MockRepo{}
+	mockCache := &MockCache{}
+
+	orders := []*model.Order{{OrderUID: "1"}, {OrderUID: "2"}, {OrderUID: "3"}}
+	results := []model.SaveResult{
+		{OrderUID: "1", Status: model.SaveInserted},
+		{OrderUID: "2", Status: model.SaveDuplicate},
+		{OrderUID: "3", Status: model.SaveFailed, Err: errors.New("db error")},
+	}
+	mockRepo.On("CreateOrders", mock.Anything, orders, model.ConflictIgnore).Return(results, nil)
+	mockCache.On("Set", mock.Anything, "1", orders[0], mock.Anything).Return(nil).Once()
+	mockCache.On("Set", mock.Anything, mock.Anything, "1", mock.Anything).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+	got, err := svc.CreateOrders(context.Background(), orders)
+	assert.NoError(t, err)
+	assert.Equal(t, results, got)
+
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
 func TestOrderService_GetOrder(t *testing.T) {
 	type mockBehavior func(r *MockRepo, c *MockCache, order *model.Order)
 	tests := []struct {
@@ -176,3 +402,620 @@ func TestOrderService_GetOrder(t *testing.T) {
 		})
 	}
 }
+
+func TestOrderService_GetOrderByTrackNumber(t *testing.T) {
+	type mockBehavior func(r *MockRepo, c *MockCache, order *model.Order)
+	tests := []struct {
+		name         string
+		order        *model.Order
+		mockBehavior mockBehavior
+		wantErr      error
+	}{
+		{
+			name:  "secondary_key_cached",
+			order: &model.Order{OrderUID: "034", TrackNumber: "WBTRACK"},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				c.On("Get", mock.Anything, "track:WBTRACK", mock.Anything).
+					Run(func(args mock.Arguments) { *args.Get(2).(*string) = order.OrderUID }).
+					Return(nil)
+				c.On("Get", mock.Anything, order.OrderUID, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:  "secondary_key_miss",
+			order: &model.Order{OrderUID: "034", TrackNumber: "WBTRACK"},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				c.On("Get", mock.Anything, "track:WBTRACK", mock.Anything).Return(redis.ErrCacheMiss)
+				r.On("GetOrderUIDByTrackNumber", mock.Anything, "WBTRACK").Return(order.OrderUID, nil)
+				c.On("Set", mock.Anything, "track:WBTRACK", order.OrderUID, mock.Anything).Return(nil)
+				c.On("Get", mock.Anything, order.OrderUID, mock.Anything).Return(redis.ErrCacheMiss)
+				r.On("GetOrder", mock.Anything, order.OrderUID).Return(order, nil)
+				c.On("Set", mock.Anything, order.OrderUID, order, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name:  "not_found",
+			order: &model.Order{OrderUID: "034", TrackNumber: "WBTRACK"},
+			mockBehavior: func(r *MockRepo, c *MockCache, order *model.Order) {
+				c.On("Get", mock.Anything, "track:WBTRACK", mock.Anything).Return(redis.ErrCacheMiss)
+				r.On("GetOrderUIDByTrackNumber", mock.Anything, "WBTRACK").Return("", model.ErrNotFound)
+			},
+			wantErr: model.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &MockRepo{}
+			mockCache := &MockCache{}
+			tt.mockBehavior(mockRepo, mockCache, tt.order)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+			order, err := svc.GetOrderByTrackNumber(context.Background(), tt.order.TrackNumber)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, order)
+			} else {
+				assert.NoError(t, err)
+				assert.NotNil(t, order)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_WarmUp(t *testing.T) {
+	t.Parallel()
+	mockRepo := &MockRepo{}
+	mockCache := &MockCache{}
+
+	first := &model.OrderPage{
+		Orders:     []*model.Order{{OrderUID: "1"}, {OrderUID: "2"}},
+		NextCursor: "next",
+	}
+	second := &model.OrderPage{
+		Orders: []*model.Order{{OrderUID: "3"}},
+	}
+	mockRepo.On("ListOrders", mock.Anything, mock.MatchedBy(func(f model.OrderFilter) bool {
+		return f.Cursor == "" && f.Limit == 3
+	})).Return(first, nil).Once()
+	mockRepo.On("ListOrders", mock.Anything, mock.MatchedBy(func(f model.OrderFilter) bool {
+		return f.Cursor == "next" && f.Limit == 1
+	})).Return(second, nil).Once()
+	for _, uid := range []string{"1", "2", "3"} {
+		mockCache.On("Set", mock.Anything, uid, mock.Anything, mock.Anything).Return(nil).Once()
+	}
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.AnythingOfType("string"), mock.Anything).Return(nil)
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+	err := svc.WarmUp(context.Background(), 3, 0, 4)
+	assert.NoError(t, err)
+
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestOrderService_GetOrderCoalescesLoads(t *testing.T) {
+	t.Parallel()
+	const callers = 10
+
+	mockRepo := &MockRepo{}
+	mockCache := &MockCache{}
+	order := &model.Order{OrderUID: "034", Items: []model.Item{{Name: "Mascaras"}}}
+
+	var misses sync.WaitGroup
+	misses.Add(callers)
+	release := make(chan struct{})
+
+	mockCache.On("Get", mock.Anything, order.OrderUID, mock.Anything).
+		Run(func(mock.Arguments) { misses.Done() }).
+		Return(redis.ErrCacheMiss)
+	mockRepo.On("GetOrder", mock.Anything, order.OrderUID).
+		Run(func(mock.Arguments) { <-release }).
+		Return(order, nil).Once()
+	mockCache.On("Set", mock.Anything, order.OrderUID, order, mock.Anything).Return(nil).Once()
+
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	testMetrics := metrics.NewTestMetrics()
+	svc := New(logger, mockRepo, mockCache, testMetrics, noop.NewTracerProvider().Tracer("test"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			got, err := svc.GetOrder(context.Background(), order.OrderUID)
+			if !assert.NoError(t, err) {
+				return
+			}
+			assert.Equal(t, "Mascaras", got.Items[0].Name)
+			got.Items[0].Name = "changed"
+		}()
+	}
+
+	misses.Wait()
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, "Mascaras", order.Items[0].Name)
+
+	assert.Equal(t, float64(callers-1), testutil.ToFloat64(testMetrics.CoalescedLoads))
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestOrderService_NegativeCache(t *testing.T) {
+	type mockBehavior func(r *MockRepo, c *MockCache)
+	tests := []struct {
+		name         string
+		mockBehavior mockBehavior
+		call         func(svc *OrderService) error
+		wantErr      error
+		wantHits     float64
+	}{
+		{
+			name: "not_found_is_cached",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				c.On("Get", mock.Anything, "034", mock.Anything).Return(redis.ErrCacheMiss)
+				c.On("Get", mock.Anything, "notfound:034", mock.Anything).Return(redis.ErrCacheMiss)
+				r.On("GetOrder", mock.Anything, "034").Return((*model.Order)(nil), model.ErrNotFound)
+				c.On("Set", mock.Anything, "notfound:034", true, time.Minute).Return(nil)
+			},
+			call: func(svc *OrderService) error {
+				_, err := svc.GetOrder(context.Background(), "034")
+				return err
+			},
+			wantErr: model.ErrNotFound,
+		},
+		{
+			name: "insert_during_load_drops_negative_entry",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				c.On("Get", mock.Anything, "034", mock.Anything).Return(redis.ErrCacheMiss)
+				c.On("Get", mock.Anything, "notfound:034", mock.Anything).Return(redis.ErrCacheMiss)
+				r.On("GetOrder", mock.Anything, "034").Return((*model.Order)(nil), model.ErrNotFound).Once()
+				c.On("Set", mock.Anything, "notfound:034", true, time.Minute).Return(nil).Once()
+				r.On("GetOrder", mock.Anything, "034").Return(&model.Order{OrderUID: "034"}, nil).Once()
+				c.On("Delete", mock.Anything, "notfound:034").Return(nil).Once()
+				c.On("Set", mock.Anything, "034", mock.Anything, 24*time.Hour).Return(nil).Once()
+			},
+			call: func(svc *OrderService) error {
+				_, err := svc.GetOrder(context.Background(), "034")
+				return err
+			},
+		},
+		{
+			name: "negative_hit_skips_repo",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				c.On("Get", mock.Anything, "034", mock.Anything).Return(redis.ErrCacheMiss)
+				c.On("Get", mock.Anything, "notfound:034", mock.Anything).
+					Run(func(args mock.Arguments) { *args.Get(2).(*bool) = true }).
+					Return(nil)
+			},
+			call: func(svc *OrderService) error {
+				_, err := svc.GetOrder(context.Background(), "034")
+				return err
+			},
+			wantErr:  model.ErrNotFound,
+			wantHits: 1,
+		},
+		{
+			name: "create_invalidates_negative_entry",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("CreateOrder", mock.Anything, mock.Anything, mock.Anything).Return(model.SaveInserted, nil)
+				c.On("Delete", mock.Anything, "notfound:034").Return(nil).Once()
+				c.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+			call: func(svc *OrderService) error {
+				_, err := svc.CreateOrder(context.Background(), &model.Order{OrderUID: "034"})
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &MockRepo{}
+			mockCache := &MockCache{}
+			tt.mockBehavior(mockRepo, mockCache)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			testMetrics := metrics.NewTestMetrics()
+			svc := New(logger, mockRepo, mockCache, testMetrics, noop.NewTracerProvider().Tracer("test"),
+				WithNegativeTTL(time.Minute))
+
+			err := tt.call(svc)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+			assert.Equal(t, tt.wantHits, testutil.ToFloat64(testMetrics.NegativeCacheHits))
+
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestCanTransition(t *testing.T) {
+	assert.True(t, CanTransition(model.StatusCreated, model.StatusPaid))
+	assert.True(t, CanTransition(model.StatusShipped, model.StatusReturned))
+	assert.False(t, CanTransition(model.StatusCreated, model.StatusDelivered))
+	assert.False(t, CanTransition(model.StatusCancelled, model.StatusPaid))
+	assert.False(t, CanTransition(model.StatusPaid, model.StatusPaid))
+}
+
+func TestOrderService_UpdateOrderStatus(t *testing.T) {
+	type mockBehavior func(r *MockRepo, c *MockCache)
+	tests := []struct {
+		name         string
+		to           model.OrderStatus
+		mockBehavior mockBehavior
+		wantErr      error
+	}{
+		{
+			name: "success",
+			to:   model.StatusPaid,
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.StatusCreated, nil)
+				r.On("UpdateOrderStatus", mock.Anything, &model.StatusChange{
+					OrderUID: "034", From: model.StatusCreated, To: model.StatusPaid, Reason: "paid online",
+				}).Return(nil)
+				c.On("Delete", mock.Anything, "034").Return(nil)
+			},
+		},
+		{
+			name:         "unknown_status",
+			to:           "lost",
+			mockBehavior: func(r *MockRepo, c *MockCache) {},
+			wantErr:      model.ErrInvalidStatus,
+		},
+		{
+			name: "illegal_transition",
+			to:   model.StatusDelivered,
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.StatusCreated, nil)
+			},
+			wantErr: model.ErrInvalidTransition,
+		},
+		{
+			name: "not_found",
+			to:   model.StatusPaid,
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.OrderStatus(""), model.ErrNotFound)
+			},
+			wantErr: model.ErrNotFound,
+		},
+		{
+			name: "concurrent_change",
+			to:   model.StatusPaid,
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.StatusCreated, nil)
+				r.On("UpdateOrderStatus", mock.Anything, mock.Anything).Return(model.ErrStatusConflict)
+			},
+			wantErr: model.ErrStatusConflict,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &MockRepo{}
+			mockCache := &MockCache{}
+			tt.mockBehavior(mockRepo, mockCache)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+			change, err := svc.UpdateOrderStatus(context.Background(), "034", tt.to, "paid online")
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+				assert.Nil(t, change)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.to, change.To)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_ApplyStatusEvent(t *testing.T) {
+	event := &model.StatusEvent{EventID: "evt-1", OrderUID: "034", Status: model.StatusShipped, Reason: "left warehouse"}
+
+	type mockBehavior func(r *MockRepo, c *MockCache)
+	tests := []struct {
+		name         string
+		mockBehavior mockBehavior
+		wantErr      error
+	}{
+		{
+			name: "applied",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("StatusEventApplied", mock.Anything, "evt-1").Return(false, nil)
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.StatusAssembling, nil)
+				r.On("UpdateOrderStatus", mock.Anything, &model.StatusChange{
+					EventID: "evt-1", OrderUID: "034", From: model.StatusAssembling, To: model.StatusShipped, Reason: "left warehouse",
+				}).Return(nil)
+				c.On("Delete", mock.Anything, "034").Return(nil)
+			},
+		},
+		{
+			name: "already_applied",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("StatusEventApplied", mock.Anything, "evt-1").Return(true, nil)
+			},
+		},
+		{
+			name: "applied_concurrently",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("StatusEventApplied", mock.Anything, "evt-1").Return(false, nil)
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.StatusAssembling, nil)
+				r.On("UpdateOrderStatus", mock.Anything, mock.Anything).Return(model.ErrDuplicateEvent)
+			},
+		},
+		{
+			name: "illegal_transition",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("StatusEventApplied", mock.Anything, "evt-1").Return(false, nil)
+				r.On("GetOrderStatus", mock.Anything, "034").Return(model.StatusCancelled, nil)
+			},
+			wantErr: model.ErrInvalidTransition,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &MockRepo{}
+			mockCache := &MockCache{}
+			tt.mockBehavior(mockRepo, mockCache)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+			e := *event
+			err := svc.ApplyStatusEvent(context.Background(), &e)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_IngestOrder(t *testing.T) {
+	src := model.MessageSource{Topic: "orders", Partition: 1, Offset: 42, PayloadHash: "abc"}
+
+	type mockBehavior func(r *MockRepo, c *MockCache)
+	tests := []struct {
+		name         string
+		mockBehavior mockBehavior
+		wantErr      bool
+	}{
+		{
+			name: "new_message",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetIngestion", mock.Anything, "orders", 1, int64(42)).Return(nil, model.ErrNotFound)
+				r.On("IngestOrder", mock.Anything, mock.Anything, src, model.ConflictIgnore).Return(model.SaveInserted, nil)
+				c.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+			},
+		},
+		{
+			name: "replay",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetIngestion", mock.Anything, "orders", 1, int64(42)).
+					Return(&model.IngestionRecord{OrderUID: "034", PayloadHash: "abc"}, nil)
+			},
+		},
+		{
+			name: "conflicting_content",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetIngestion", mock.Anything, "orders", 1, int64(42)).Return(nil, model.ErrNotFound)
+				r.On("IngestOrder", mock.Anything, mock.Anything, src, model.ConflictIgnore).Return(model.SaveIgnored, nil)
+			},
+		},
+		{
+			name: "db_error",
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetIngestion", mock.Anything, "orders", 1, int64(42)).Return(nil, model.ErrNotFound)
+				r.On("IngestOrder", mock.Anything, mock.Anything, src, model.ConflictIgnore).Return(model.SaveFailed, errors.New("db error"))
+			},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &MockRepo{}
+			mockCache := &MockCache{}
+			tt.mockBehavior(mockRepo, mockCache)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+			err := svc.IngestOrder(context.Background(), &model.Order{OrderUID: "034"}, src)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_EraseOrder(t *testing.T) {
+	order := &model.Order{
+		OrderUID:    "034",
+		TrackNumber: "WBILMTESTTRACK",
+		Payment:     model.Payment{Transaction: "034"},
+		Items:       []model.Item{{Rid: "ab4219087a764ae0btest"}},
+	}
+
+	type mockBehavior func(r *MockRepo, c *MockCache)
+	tests := []struct {
+		name         string
+		erasure      model.Erasure
+		mockBehavior mockBehavior
+		wantErr      error
+	}{
+		{
+			name:    "anonymize",
+			erasure: model.Erasure{OrderUID: "034", Mode: model.ErasureAnonymize, RequestedBy: "dpo@example.com"},
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetOrder", mock.Anything, "034").Return(order, nil)
+				r.On("EraseOrder", mock.Anything, mock.Anything).Return(nil)
+				c.On("Delete", mock.Anything, "034").Return(nil)
+				c.On("Delete", mock.Anything, "track:WBILMTESTTRACK").Return(nil)
+				c.On("Delete", mock.Anything, "tx:034").Return(nil)
+				c.On("Delete", mock.Anything, "rid:ab4219087a764ae0btest").Return(nil)
+			},
+		},
+		{
+			name:         "unknown_mode",
+			erasure:      model.Erasure{OrderUID: "034", Mode: "shred", RequestedBy: "dpo@example.com"},
+			mockBehavior: func(r *MockRepo, c *MockCache) {},
+			wantErr:      model.ErrInvalidErasure,
+		},
+		{
+			name:         "missing_requester",
+			erasure:      model.Erasure{OrderUID: "034", Mode: model.ErasureDelete},
+			mockBehavior: func(r *MockRepo, c *MockCache) {},
+			wantErr:      model.ErrInvalidErasure,
+		},
+		{
+			name:    "not_found",
+			erasure: model.Erasure{OrderUID: "034", Mode: model.ErasureDelete, RequestedBy: "dpo@example.com"},
+			mockBehavior: func(r *MockRepo, c *MockCache) {
+				r.On("GetOrder", mock.Anything, "034").Return(nil, model.ErrNotFound)
+			},
+			wantErr: model.ErrNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+			mockRepo := &MockRepo{}
+			mockCache := &MockCache{}
+			tt.mockBehavior(mockRepo, mockCache)
+			logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+			svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+			err := svc.EraseOrder(context.Background(), &tt.erasure)
+			if tt.wantErr != nil {
+				assert.ErrorIs(t, err, tt.wantErr)
+			} else {
+				assert.NoError(t, err)
+			}
+
+			mockRepo.AssertExpectations(t)
+			mockCache.AssertExpectations(t)
+		})
+	}
+}
+
+func TestOrderService_IngestOrders(t *testing.T) {
+	mockRepo := &MockRepo{}
+	mockCache := &MockCache{}
+
+	orders := []*model.Order{{OrderUID: "1"}, {OrderUID: "2"}, {OrderUID: "3"}}
+	srcs := []model.MessageSource{
+		{Topic: "orders", Offset: 1, PayloadHash: "a"},
+		{Topic: "orders", Offset: 2, PayloadHash: "b"},
+		{Topic: "orders", Offset: 3, PayloadHash: "c"},
+	}
+	mockRepo.On("GetIngestions", mock.Anything, srcs).Return([]model.IngestionRecord{
+		{Topic: "orders", Offset: 2, OrderUID: "2", PayloadHash: "b"},
+	}, nil).Once()
+	mockRepo.On("IngestOrders", mock.Anything, []*model.Order{orders[0], orders[2]},
+		[]model.MessageSource{srcs[0], srcs[2]}, model.ConflictIgnore).
+		Return([]model.SaveResult{
+			{OrderUID: "1", Status: model.SaveInserted},
+			{OrderUID: "3", Status: model.SaveConflict},
+		}, nil)
+	mockCache.On("Set", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+	results, err := svc.IngestOrders(context.Background(), orders, srcs)
+	assert.NoError(t, err)
+	if !assert.Len(t, results, 3) {
+		return
+	}
+	assert.Equal(t, model.SaveInserted, results[0].Status)
+	assert.NoError(t, results[0].Err)
+	assert.Equal(t, model.SaveDuplicate, results[1].Status)
+	assert.ErrorIs(t, results[2].Err, model.ErrOrderConflict)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderService_PurgeIngestions(t *testing.T) {
+	mockRepo := &MockRepo{}
+	mockRepo.On("DeleteIngestions", mock.Anything, mock.Anything, 100).Return(int64(100), nil).Once()
+	mockRepo.On("DeleteIngestions", mock.Anything, mock.Anything, 100).Return(int64(7), nil).Once()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(logger, mockRepo, &MockCache{}, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+	purged, err := svc.PurgeIngestions(context.Background(), 30*24*time.Hour, 100)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(107), purged)
+
+	mockRepo.AssertExpectations(t)
+}
+
+func TestOrderService_ArchiveOrders(t *testing.T) {
+	mockRepo := &MockRepo{}
+	mockCache := &MockCache{}
+	mockRepo.On("ArchiveOrders", mock.Anything, mock.Anything, 2).Return([]string{"a", "b"}, nil).Once()
+	mockRepo.On("ArchiveOrders", mock.Anything, mock.Anything, 2).Return([]string{"c"}, nil).Once()
+	for _, uid := range []string{"a", "b", "c"} {
+		mockCache.On("Delete", mock.Anything, uid).Return(nil)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+	archived, err := svc.ArchiveOrders(context.Background(), 90*24*time.Hour, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, archived)
+
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
+
+func TestOrderService_MaintainPartitions(t *testing.T) {
+	now := time.Now().UTC()
+	wantBefore := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, time.UTC).AddDate(0, -12, 0)
+
+	mockRepo := &MockRepo{}
+	mockRepo.On("CreatePartitions", mock.Anything, mock.Anything, mock.MatchedBy(func(to time.Time) bool {
+		return to.Sub(now) > 80*24*time.Hour
+	})).Return(nil).Twice()
+	mockRepo.On("ArchiveOrders", mock.Anything, wantBefore, partitionArchiveBatch).Return([]string{"a"}, nil).Once()
+	mockRepo.On("DropPartitions", mock.Anything, wantBefore).Return([]string{"202001"}, nil).Once()
+	mockCache := &MockCache{}
+	mockCache.On("Delete", mock.Anything, "a").Return(nil)
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	svc := New(logger, mockRepo, mockCache, metrics.NewTestMetrics(), noop.NewTracerProvider().Tracer("test"))
+
+	assert.NoError(t, svc.MaintainPartitions(context.Background(), 3, 12))
+	assert.NoError(t, svc.MaintainPartitions(context.Background(), 3, 0))
+
+	mockRepo.AssertExpectations(t)
+	mockCache.AssertExpectations(t)
+}
@@ -0,0 +1,146 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+)
+
+var transitions = map[model.OrderStatus][]model.OrderStatus{
+	model.StatusCreated:    {model.StatusPaid, model.StatusCancelled},
+	model.StatusPaid:       {model.StatusAssembling, model.StatusCancelled},
+	model.StatusAssembling: {model.StatusShipped, model.StatusCancelled},
+	model.StatusShipped:    {model.StatusDelivered, model.StatusReturned},
+	model.StatusDelivered:  {model.StatusReturned},
+	model.StatusCancelled:  {},
+	model.StatusReturned:   {},
+}
+
+func CanTransition(from, to model.OrderStatus) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *OrderService) UpdateOrderStatus(ctx context.Context, orderUID string, to model.OrderStatus, reason string) (*model.StatusChange, error) {
+	const op = "service.UpdateOrderStatus"
+
+	ctx, span := s.tr.Start(ctx, "service.UpdateOrderStatus")
+	defer span.End()
+	span.SetAttributes(attribute.String("order_uid", orderUID), attribute.String("to", string(to)))
+
+	change := &model.StatusChange{
+		OrderUID: orderUID,
+		To:       to,
+		Reason:   reason,
+	}
+	if err := s.changeStatus(ctx, change); err != nil {
+		if isStatusError(err) {
+			return nil, err
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return change, nil
+}
+
+// ApplyStatusEvent applies a status change reported by another system.
+// Events that were already applied are skipped without an error.
+func (s *OrderService) ApplyStatusEvent(ctx context.Context, event *model.StatusEvent) error {
+	const op = "service.ApplyStatusEvent"
+
+	ctx, span := s.tr.Start(ctx, "service.ApplyStatusEvent")
+	defer span.End()
+	span.SetAttributes(
+		attribute.String("event_id", event.EventID),
+		attribute.String("order_uid", event.OrderUID),
+		attribute.String("to", string(event.Status)),
+	)
+
+	applied, err := s.repo.StatusEventApplied(ctx, event.EventID)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if applied {
+		s.m.StatusEvents.WithLabelValues("duplicate").Inc()
+		s.l.Debug("status event already applied", "event_id", event.EventID)
+		return nil
+	}
+
+	change := &model.StatusChange{
+		EventID:  event.EventID,
+		OrderUID: event.OrderUID,
+		To:       event.Status,
+		Reason:   event.Reason,
+	}
+	if err := s.changeStatus(ctx, change); err != nil {
+		if errors.Is(err, model.ErrDuplicateEvent) {
+			s.m.StatusEvents.WithLabelValues("duplicate").Inc()
+			return nil
+		}
+		if isStatusError(err) {
+			s.m.StatusEvents.WithLabelValues("rejected").Inc()
+			return err
+		}
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	s.m.StatusEvents.WithLabelValues("applied").Inc()
+	return nil
+}
+
+func (s *OrderService) changeStatus(ctx context.Context, change *model.StatusChange) error {
+	if !change.To.Valid() {
+		return fmt.Errorf("%w: %q", model.ErrInvalidStatus, change.To)
+	}
+
+	from, err := s.repo.GetOrderStatus(ctx, change.OrderUID)
+	if err != nil {
+		return err
+	}
+	if !CanTransition(from, change.To) {
+		return fmt.Errorf("%w: %s -> %s", model.ErrInvalidTransition, from, change.To)
+	}
+
+	change.From = from
+	if err := s.repo.UpdateOrderStatus(ctx, change); err != nil {
+		return err
+	}
+
+	s.deleteCache(ctx, change.OrderUID)
+	return nil
+}
+
+func isStatusError(err error) bool {
+	return errors.Is(err, model.ErrNotFound) ||
+		errors.Is(err, model.ErrInvalidStatus) ||
+		errors.Is(err, model.ErrInvalidTransition) ||
+		errors.Is(err, model.ErrStatusConflict)
+}
+
+func (s *OrderService) GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error) {
+	const op = "service.GetStatusHistory"
+
+	ctx, span := s.tr.Start(ctx, "service.GetStatusHistory")
+	defer span.End()
+
+	history, err := s.repo.GetStatusHistory(ctx, orderUID)
+	if err != nil {
+		if errors.Is(err, model.ErrNotFound) {
+			return nil, model.ErrNotFound
+		}
+		return nil, fmt.Errorf("%s: %w", op, err)
+	}
+	return history, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"golang.org/x/sync/errgroup"
+)
+
+const warmupPageSize = 100
+
+func (s *OrderService) WarmUp(ctx context.Context, limit int, maxAge time.Duration, concurrency int) error {
+	const op = "service.WarmUp"
+
+	ctx, span := s.tr.Start(ctx, "service.WarmUp")
+	defer span.End()
+	defer s.m.WarmupDone.Set(1)
+
+	start := time.Now()
+	filter := model.OrderFilter{Sort: model.SortCreatedDesc, Limit: warmupPageSize}
+	if maxAge > 0 {
+		filter.CreatedFrom = start.Add(-maxAge)
+	}
+
+	s.l.Info("cache warm-up started", "limit", limit, "max_age", maxAge, "concurrency", concurrency)
+
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(max(concurrency, 1))
+
+	loaded := 0
+	for limit <= 0 || loaded < limit {
+		if limit > 0 {
+			filter.Limit = min(warmupPageSize, limit-loaded)
+		}
+
+		page, err := s.repo.ListOrders(gctx, filter)
+		if err != nil {
+			_ = g.Wait()
+			span.RecordError(err)
+			span.SetStatus(codes.Error, err.Error())
+			return fmt.Errorf("%s: loaded %d orders: %w", op, loaded, err)
+		}
+
+		for _, order := range page.Orders {
+			g.Go(func() error {
+				s.setCache(gctx, order.OrderUID, order, 24*time.Hour)
+				s.setSecondaryKeys(gctx, order, 24*time.Hour)
+				s.m.WarmupOrders.Inc()
+				return nil
+			})
+		}
+		loaded += len(page.Orders)
+		s.l.Info("cache warm-up progress", "loaded", loaded)
+
+		if page.NextCursor == "" {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+
+	if err := g.Wait(); err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%s: loaded %d orders: %w", op, loaded, err)
+	}
+	span.SetAttributes(attribute.Int("orders", loaded))
+
+	s.l.Info("cache warm-up finished", "orders", loaded, "duration", time.Since(start).String())
+	return nil
+}
@@ -5,7 +5,9 @@ import (
 	"errors"
 	"fmt"
 	"log/slog"
-	"time"
+	"net"
+	"net/url"
+	"strconv"
 
 	"github.com/MikebangSfilya/wb/internal/config"
 	"github.com/golang-migrate/migrate/v4"
@@ -15,35 +17,25 @@ import (
 )
 
 type Storage struct {
-	Pool *pgxpool.Pool
+	Pool     *pgxpool.Pool
+	Replicas []*pgxpool.Pool
 }
 
 func New(ctx context.Context, cfg *config.Config) (*Storage, error) {
 	const op = "storage.Pool.New"
 
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.Name,
-	)
-	slog.Info("connecting to database",
-		slog.String("op", op),
-		slog.String("host", cfg.Database.Host),
-		slog.String("database", cfg.Database.Name),
-	)
-
-	dbConfig, err := pgxpool.ParseConfig(connStr)
+	poolCfg, err := poolConfig(cfg.Database)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	dbConfig.MaxConns = 10
-	dbConfig.MinConns = 2
-	dbConfig.MaxConnLifetime = time.Hour
+	slog.Info("connecting to database",
+		slog.String("op", op),
+		slog.String("host", poolCfg.ConnConfig.Host),
+		slog.String("database", poolCfg.ConnConfig.Database),
+	)
 
-	dbPool, err := pgxpool.NewWithConfig(ctx, dbConfig)
+	dbPool, err := pgxpool.NewWithConfig(ctx, poolCfg)
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -53,26 +45,137 @@ func New(ctx context.Context, cfg *config.Config) (*Storage, error) {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
 
-	slog.Info("Database connected successfully!")
-	return &Storage{Pool: dbPool}, nil
+	s := &Storage{Pool: dbPool}
+	for _, addr := range cfg.Database.Replicas {
+		replicaCfg, err := replicaConfig(poolCfg, addr)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("%s: replica %s: %w", op, addr, err)
+		}
+		// An unreachable replica is not fatal: reads fall back to the primary
+		// until the replica passes a health check.
+		replica, err := pgxpool.NewWithConfig(ctx, replicaCfg)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("%s: replica %s: %w", op, addr, err)
+		}
+		if err := replica.Ping(ctx); err != nil {
+			slog.Warn("database replica is not reachable", slog.String("replica", addr), slog.String("error", err.Error()))
+		}
+		s.Replicas = append(s.Replicas, replica)
+	}
+
+	slog.Info("Database connected successfully!", slog.Int("replicas", len(s.Replicas)))
+	return s, nil
+}
+
+// connString returns db.DSN when it is set, otherwise a postgres:// URL built
+// from the discrete options with credentials and paths escaped.
+func connString(db config.DatabaseConfig) string {
+	if db.DSN != "" {
+		return db.DSN
+	}
+
+	q := url.Values{}
+	sslMode := db.SSLMode
+	if sslMode == "" {
+		sslMode = "disable"
+	}
+	q.Set("sslmode", sslMode)
+	if db.SSLRootCert != "" {
+		q.Set("sslrootcert", db.SSLRootCert)
+	}
+	if db.SSLCert != "" {
+		q.Set("sslcert", db.SSLCert)
+	}
+	if db.SSLKey != "" {
+		q.Set("sslkey", db.SSLKey)
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(db.User, db.Password),
+		Host:     net.JoinHostPort(db.Host, db.Port),
+		Path:     "/" + db.Name,
+		RawQuery: q.Encode(),
+	}
+	return u.String()
+}
+
+func poolConfig(db config.DatabaseConfig) (*pgxpool.Config, error) {
+	poolCfg, err := pgxpool.ParseConfig(connString(db))
+	if err != nil {
+		return nil, err
+	}
+
+	if db.MaxConns > 0 {
+		poolCfg.MaxConns = db.MaxConns
+	}
+	if db.MinConns > 0 {
+		poolCfg.MinConns = db.MinConns
+	}
+	if db.MaxConnLifetime > 0 {
+		poolCfg.MaxConnLifetime = db.MaxConnLifetime
+	}
+	if db.MaxConnIdleTime > 0 {
+		poolCfg.MaxConnIdleTime = db.MaxConnIdleTime
+	}
+	if db.HealthCheckPeriod > 0 {
+		poolCfg.HealthCheckPeriod = db.HealthCheckPeriod
+	}
+
+	params := poolCfg.ConnConfig.RuntimeParams
+	if db.StatementTimeout > 0 {
+		params["statement_timeout"] = strconv.FormatInt(db.StatementTimeout.Milliseconds(), 10)
+	}
+	if db.ApplicationName != "" && params["application_name"] == "" {
+		params["application_name"] = db.ApplicationName
+	}
+	return poolCfg, nil
+}
+
+// replicaConfig copies the primary settings and points them at addr. A
+// missing port means the primary's port.
+func replicaConfig(primary *pgxpool.Config, addr string) (*pgxpool.Config, error) {
+	cfg := primary.Copy()
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		host, port = addr, strconv.Itoa(int(primary.ConnConfig.Port))
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port %q", port)
+	}
+	cfg.ConnConfig.Host = host
+	cfg.ConnConfig.Port = uint16(p)
+	cfg.ConnConfig.Fallbacks = nil
+	if tls := cfg.ConnConfig.TLSConfig; tls != nil && tls.ServerName != "" {
+		tls.ServerName = host
+	}
+	return cfg, nil
 }
 
 func (s *Storage) Close() {
 	s.Pool.Close()
+	for _, replica := range s.Replicas {
+		replica.Close()
+	}
+}
+
+// Pools returns the primary and replica pools keyed by the name used in
+// metrics.
+func (s *Storage) Pools() map[string]*pgxpool.Pool {
+	pools := map[string]*pgxpool.Pool{"primary": s.Pool}
+	for i, replica := range s.Replicas {
+		pools["replica-"+strconv.Itoa(i)] = replica
+	}
+	return pools
 }
 
 func RunMigrations(cfg *config.Config) error {
 	const op = "storage.postgre.RunMigrations"
 
-	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
-		cfg.Database.User,
-		cfg.Database.Password,
-		cfg.Database.Host,
-		cfg.Database.Port,
-		cfg.Database.Name,
-	)
-
-	m, err := migrate.New("file://db/migrations", connStr)
+	m, err := migrate.New("file://db/migrations", connString(cfg.Database))
 	if err != nil {
 		return fmt.Errorf("%s: failed to create migrate instance: %w", op, err)
 	}
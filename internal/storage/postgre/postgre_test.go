@@ -0,0 +1,99 @@
+package postgre
+
+import (
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnString(t *testing.T) {
+	db := config.DatabaseConfig{
+		Host:     "db.internal",
+		Port:     "6432",
+		User:     "wb",
+		Password: "p@ss:w/rd?#%",
+		Name:     "orders",
+		SSLMode:  "require",
+	}
+
+	cfg, err := poolConfig(db)
+	require.NoError(t, err)
+	assert.Equal(t, "db.internal", cfg.ConnConfig.Host)
+	assert.Equal(t, uint16(6432), cfg.ConnConfig.Port)
+	assert.Equal(t, "wb", cfg.ConnConfig.User)
+	assert.Equal(t, "p@ss:w/rd?#%", cfg.ConnConfig.Password)
+	assert.Equal(t, "orders", cfg.ConnConfig.Database)
+	assert.NotNil(t, cfg.ConnConfig.TLSConfig)
+
+	db.SSLMode = "verify-full"
+	db.SSLRootCert = "/etc/ssl/root.crt"
+	db.SSLCert = "/etc/ssl/client.crt"
+	db.SSLKey = "/etc/ssl/client.key"
+	dsn := connString(db)
+	assert.Contains(t, dsn, "sslmode=verify-full")
+	assert.Contains(t, dsn, "sslrootcert=%2Fetc%2Fssl%2Froot.crt")
+	assert.Contains(t, dsn, "sslcert=%2Fetc%2Fssl%2Fclient.crt")
+	assert.Contains(t, dsn, "sslkey=%2Fetc%2Fssl%2Fclient.key")
+}
+
+func TestConnString_DSN(t *testing.T) {
+	db := config.DatabaseConfig{
+		DSN:  "postgres://u:p@primary:5432/app?sslmode=disable&application_name=custom",
+		Host: "ignored",
+	}
+	assert.Equal(t, db.DSN, connString(db))
+
+	cfg, err := poolConfig(db)
+	require.NoError(t, err)
+	assert.Equal(t, "primary", cfg.ConnConfig.Host)
+	assert.Equal(t, "custom", cfg.ConnConfig.RuntimeParams["application_name"])
+}
+
+func TestPoolConfig(t *testing.T) {
+	db := config.DatabaseConfig{
+		Host:              "localhost",
+		Port:              "5432",
+		SSLMode:           "disable",
+		MaxConns:          20,
+		MinConns:          4,
+		MaxConnLifetime:   30 * time.Minute,
+		MaxConnIdleTime:   5 * time.Minute,
+		HealthCheckPeriod: 15 * time.Second,
+		StatementTimeout:  3 * time.Second,
+		ApplicationName:   "wb-service",
+	}
+
+	cfg, err := poolConfig(db)
+	require.NoError(t, err)
+	assert.Equal(t, int32(20), cfg.MaxConns)
+	assert.Equal(t, int32(4), cfg.MinConns)
+	assert.Equal(t, 30*time.Minute, cfg.MaxConnLifetime)
+	assert.Equal(t, 5*time.Minute, cfg.MaxConnIdleTime)
+	assert.Equal(t, 15*time.Second, cfg.HealthCheckPeriod)
+	assert.Equal(t, "3000", cfg.ConnConfig.RuntimeParams["statement_timeout"])
+	assert.Equal(t, "wb-service", cfg.ConnConfig.RuntimeParams["application_name"])
+}
+
+func TestReplicaConfig(t *testing.T) {
+	primary, err := poolConfig(config.DatabaseConfig{
+		Host: "primary", Port: "5432", User: "wb", Password: "secret", Name: "orders", SSLMode: "disable",
+		MaxConns: 7,
+	})
+	require.NoError(t, err)
+
+	cfg, err := replicaConfig(primary, "replica-1:5433")
+	require.NoError(t, err)
+	assert.Equal(t, "replica-1", cfg.ConnConfig.Host)
+	assert.Equal(t, uint16(5433), cfg.ConnConfig.Port)
+	assert.Equal(t, "secret", cfg.ConnConfig.Password)
+	assert.Equal(t, int32(7), cfg.MaxConns)
+	assert.Equal(t, "primary", primary.ConnConfig.Host)
+
+	cfg, err = replicaConfig(primary, "replica-2")
+	require.NoError(t, err)
+	assert.Equal(t, "replica-2", cfg.ConnConfig.Host)
+	assert.Equal(t, uint16(5432), cfg.ConnConfig.Port)
+}
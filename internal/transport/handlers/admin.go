@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetIngestions shows which Kafka messages produced or re-sent the order.
+func (h *Handler) GetIngestions() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		records, err := h.service.ListIngestions(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				http.Error(w, "no ingested messages for order", http.StatusNotFound)
+				return
+			}
+			h.l.Error("failed to list ingestions", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(records); err != nil {
+			h.l.Error("failed to encode response", "error", err)
+			return
+		}
+	}
+}
+
+type eraseOrderRequest struct {
+	Mode   model.ErasureMode `json:"mode"`
+	Reason string            `json:"reason"`
+}
+
+// EraseOrder deletes or anonymizes the personal data of an order. The
+// request is recorded under the principal authenticated by AdminAuth.
+func (h *Handler) EraseOrder() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		var req eraseOrderRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		erasure := &model.Erasure{
+			OrderUID:    id,
+			Mode:        req.Mode,
+			RequestedBy: Principal(r.Context()),
+			Reason:      req.Reason,
+		}
+		if err := h.service.EraseOrder(r.Context(), erasure); err != nil {
+			switch {
+			case errors.Is(err, model.ErrNotFound):
+				http.Error(w, "order not found", http.StatusNotFound)
+			case errors.Is(err, model.ErrInvalidErasure):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			default:
+				h.l.Error("failed to erase order", "error", err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(erasure); err != nil {
+			h.l.Error("failed to encode response", "error", err)
+			return
+		}
+	}
+}
+
+func (h *Handler) GetErasures() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		erasures, err := h.service.ListErasures(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				http.Error(w, "no erasures for order", http.StatusNotFound)
+				return
+			}
+			h.l.Error("failed to list erasures", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(erasures); err != nil {
+			h.l.Error("failed to encode response", "error", err)
+			return
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package handlers
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+type principalKey struct{}
+
+// AdminAuth lets through requests that carry "Authorization: Bearer <token>"
+// with one of tokens, a map of principal name to token, and stores the
+// principal in the request context.
+func AdminAuth(tokens map[string]string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if !ok || token == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			principal := ""
+			for name, want := range tokens {
+				if subtle.ConstantTimeCompare([]byte(token), []byte(want)) == 1 {
+					principal = name
+				}
+			}
+			if principal == "" {
+				w.Header().Set("WWW-Authenticate", "Bearer")
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), principalKey{}, principal)))
+		})
+	}
+}
+
+// Principal returns the name of the caller authenticated by AdminAuth.
+func Principal(ctx context.Context) string {
+	p, _ := ctx.Value(principalKey{}).(string)
+	return p
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/go-chi/chi/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func (m *MockService) EraseOrder(ctx context.Context, e *model.Erasure) error {
+	args := m.Called(ctx, e)
+	return args.Error(0)
+}
+
+func TestAdminAuth(t *testing.T) {
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc := new(MockService)
+	svc.On("EraseOrder", mock.Anything, mock.MatchedBy(func(e *model.Erasure) bool {
+		return e.RequestedBy == "dpo" && e.Mode == model.ErasureAnonymize
+	})).Return(nil).Once()
+
+	router := chi.NewRouter()
+	router.Route("/admin", func(admin chi.Router) {
+		admin.Use(AdminAuth(map[string]string{"dpo": "secret", "ops": "other"}))
+		admin.Post("/orders/{id}/erasure", New(l, svc).EraseOrder())
+	})
+
+	tests := []struct {
+		name     string
+		auth     string
+		wantCode int
+	}{
+		{name: "no token", wantCode: http.StatusUnauthorized},
+		{name: "wrong token", auth: "Bearer nope", wantCode: http.StatusUnauthorized},
+		{name: "not bearer", auth: "Basic c2VjcmV0", wantCode: http.StatusUnauthorized},
+		{name: "authorized", auth: "Bearer secret", wantCode: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := `{"mode": "anonymize", "requested_by": "someone-else"}`
+			req := httptest.NewRequest(http.MethodPost, "/admin/orders/034/erasure", strings.NewReader(body))
+			if tt.auth != "" {
+				req.Header.Set("Authorization", tt.auth)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantCode, rec.Code)
+		})
+	}
+	svc.AssertExpectations(t)
+}
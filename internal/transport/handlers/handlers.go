@@ -6,35 +6,107 @@ import (
 	"errors"
 	"log/slog"
 	"net/http"
+	"time"
 
 	"github.com/MikebangSfilya/wb/internal/model"
 	"github.com/go-chi/chi/v5"
+	"github.com/segmentio/kafka-go"
 )
 
 type OrderService interface {
+	CreateOrder(ctx context.Context, order *model.Order) (model.SaveStatus, error)
+	CreateOrders(ctx context.Context, orders []*model.Order) ([]model.SaveResult, error)
 	GetOrder(ctx context.Context, orderUID string) (*model.Order, error)
+	ListOrders(ctx context.Context, filter model.OrderFilter) (*model.OrderPage, error)
+	GetOrderByTrackNumber(ctx context.Context, trackNumber string) (*model.Order, error)
+	GetOrderByTransaction(ctx context.Context, transaction string) (*model.Order, error)
+	GetOrderByRid(ctx context.Context, rid string) (*model.Order, error)
+	UpdateOrderStatus(ctx context.Context, orderUID string, to model.OrderStatus, reason string) (*model.StatusChange, error)
+	GetStatusHistory(ctx context.Context, orderUID string) ([]model.StatusChange, error)
+	ListIngestions(ctx context.Context, orderUID string) ([]model.IngestionRecord, error)
+	EraseOrder(ctx context.Context, e *model.Erasure) error
+	ListErasures(ctx context.Context, orderUID string) ([]model.Erasure, error)
+}
+
+type OrderPublisher interface {
+	SendMessage(ctx context.Context, key string, value []byte, headers ...kafka.Header) error
+}
+
+type IdempotencyStore interface {
+	SetNX(ctx context.Context, key string, value any, ttl time.Duration) (bool, error)
+	Set(ctx context.Context, key string, value any, ttl time.Duration) error
+	Get(ctx context.Context, key string, dest any) error
+	Delete(ctx context.Context, key string) error
 }
 
 type Handler struct {
-	service OrderService
-	l       *slog.Logger
+	service        OrderService
+	publisher      OrderPublisher
+	idempotency    IdempotencyStore
+	idempotencyTTL time.Duration
+	batchLimit     int
+	l              *slog.Logger
+}
+
+type Option func(*Handler)
+
+// WithPublisher makes the ingestion endpoints publish orders to Kafka
+// instead of saving them directly.
+func WithPublisher(p OrderPublisher) Option {
+	return func(h *Handler) {
+		h.publisher = p
+	}
+}
+
+func WithIdempotency(store IdempotencyStore, ttl time.Duration) Option {
+	return func(h *Handler) {
+		h.idempotency = store
+		h.idempotencyTTL = ttl
+	}
 }
 
-func New(l *slog.Logger, service OrderService) *Handler {
-	return &Handler{
-		service: service,
-		l:       l,
+func WithBatchLimit(limit int) Option {
+	return func(h *Handler) {
+		h.batchLimit = limit
 	}
 }
 
+func New(l *slog.Logger, service OrderService, opts ...Option) *Handler {
+	h := &Handler{
+		service:    service,
+		batchLimit: 500,
+		l:          l,
+	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
+}
+
 func (h *Handler) GetOrder() http.HandlerFunc {
+	return h.orderBy("id", h.service.GetOrder)
+}
+
+func (h *Handler) GetOrderByTrackNumber() http.HandlerFunc {
+	return h.orderBy("track", h.service.GetOrderByTrackNumber)
+}
+
+func (h *Handler) GetOrderByTransaction() http.HandlerFunc {
+	return h.orderBy("tx", h.service.GetOrderByTransaction)
+}
+
+func (h *Handler) GetOrderByRid() http.HandlerFunc {
+	return h.orderBy("rid", h.service.GetOrderByRid)
+}
+
+func (h *Handler) orderBy(param string, get func(ctx context.Context, value string) (*model.Order, error)) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		id := chi.URLParam(r, "id")
-		if id == "" {
-			http.Error(w, "id is required", http.StatusBadRequest)
+		value := chi.URLParam(r, param)
+		if value == "" {
+			http.Error(w, param+" is required", http.StatusBadRequest)
 			return
 		}
-		order, err := h.service.GetOrder(r.Context(), id)
+		order, err := get(r.Context(), value)
 		if err != nil {
 			if errors.Is(err, model.ErrNotFound) {
 				slog.Debug(err.Error())
@@ -0,0 +1,47 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+)
+
+func Ready(ready *atomic.Bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "not ready", http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("ok"))
+	}
+}
+
+// Dependency reports whether an optional dependency is degraded. The service
+// keeps serving while a dependency is degraded.
+type Dependency interface {
+	Degraded() bool
+}
+
+type healthResponse struct {
+	Status     string            `json:"status"`
+	Components map[string]string `json:"components"`
+}
+
+// Health reports the state of the given dependencies. It always responds with
+// 200; the status is "degraded" when any dependency is.
+func Health(deps map[string]Dependency) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		resp := healthResponse{Status: "ok", Components: make(map[string]string, len(deps))}
+		for name, dep := range deps {
+			state := "ok"
+			if dep.Degraded() {
+				state = "degraded"
+				resp.Status = "degraded"
+			}
+			resp.Components[name] = state
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(resp)
+	}
+}
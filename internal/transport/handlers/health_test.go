@@ -0,0 +1,41 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDependency bool
+
+func (d fakeDependency) Degraded() bool { return bool(d) }
+
+func TestHealth(t *testing.T) {
+	tests := []struct {
+		name string
+		deps map[string]Dependency
+		want string
+	}{
+		{
+			name: "ok",
+			deps: map[string]Dependency{"redis": fakeDependency(false)},
+			want: `{"status":"ok","components":{"redis":"ok"}}`,
+		},
+		{
+			name: "degraded",
+			deps: map[string]Dependency{"redis": fakeDependency(true)},
+			want: `{"status":"degraded","components":{"redis":"degraded"}}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			Health(tt.deps)(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.JSONEq(t, tt.want, rec.Body.String())
+		})
+	}
+}
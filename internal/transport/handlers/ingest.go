@@ -0,0 +1,325 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"maps"
+	"net/http"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+)
+
+// IdempotencyPrefix starts the keys of Idempotency-Key reservations and
+// stored responses in the idempotency store.
+const IdempotencyPrefix = "idempotency:"
+
+const (
+	maxOrderBody = 1 << 20
+	maxBatchBody = 16 << 20
+
+	idempotencyHeader = "Idempotency-Key"
+	// idempotencyLockTTL bounds how long a key stays reserved if the request
+	// holding it never finishes.
+	idempotencyLockTTL = time.Minute
+
+	resultAccepted = "accepted"
+	resultInvalid  = "invalid"
+)
+
+const (
+	problemInvalidBody = "/problems/invalid-body"
+	problemTooLarge    = "/problems/payload-too-large"
+	problemConflict    = "/problems/order-conflict"
+	problemErased      = "/problems/order-erased"
+	problemIdempotency = "/problems/idempotency-key-reuse"
+	problemInProgress  = "/problems/idempotency-key-in-progress"
+	problemUnavailable = "/problems/idempotency-unavailable"
+	problemInternal    = "/problems/internal-error"
+)
+
+type ingestResult struct {
+	OrderUID string                `json:"order_uid"`
+	Result   string                `json:"result"`
+	Version  int                   `json:"version,omitempty"`
+	Errors   []validator.Violation `json:"errors,omitempty"`
+	Warnings []validator.Violation `json:"warnings,omitempty"`
+	Error    string                `json:"error,omitempty"`
+}
+
+type batchResponse struct {
+	Results []ingestResult `json:"results"`
+}
+
+// response is kept for Idempotency-Key replays, so it holds the encoded body.
+type response struct {
+	Status      int               `json:"status"`
+	ContentType string            `json:"content_type"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Body        []byte            `json:"body"`
+}
+
+type idempotentResponse struct {
+	Hash     string   `json:"hash"`
+	Pending  bool     `json:"pending,omitempty"`
+	Response response `json:"response"`
+}
+
+// CreateOrder accepts a single order over HTTP. Depending on the configured
+// mode the order is saved directly or published to the orders topic.
+func (h *Handler) CreateOrder() http.HandlerFunc {
+	return h.idempotent(maxOrderBody, h.createOrder)
+}
+
+// CreateOrders accepts a JSON array of orders and reports a result per order.
+func (h *Handler) CreateOrders() http.HandlerFunc {
+	return h.idempotent(maxBatchBody, h.createOrders)
+}
+
+func (h *Handler) createOrder(ctx context.Context, body []byte) response {
+	var order model.Order
+	if err := json.Unmarshal(body, &order); err != nil {
+		return problemResponse(problem{Type: problemInvalidBody, Title: "Invalid order JSON", Status: http.StatusBadRequest, Detail: err.Error()})
+	}
+
+	warnings, err := validator.Validate(&order)
+	if err != nil {
+		return invalidResponse(err)
+	}
+	if len(warnings) > 0 {
+		h.l.Warn("order accepted with warnings", "uid", order.OrderUID, "violations", warnings)
+	}
+
+	if h.publisher != nil {
+		if err := h.publisher.SendMessage(ctx, order.OrderUID, body); err != nil {
+			h.l.Error("failed to publish order", "uid", order.OrderUID, "error", err)
+			return problemResponse(problem{Type: problemInternal, Title: "Order could not be queued", Status: http.StatusServiceUnavailable})
+		}
+		return jsonResponse(http.StatusAccepted, ingestResult{OrderUID: order.OrderUID, Result: resultAccepted, Warnings: warnings})
+	}
+
+	status, err := h.service.CreateOrder(ctx, &order)
+	if err != nil {
+		if errors.Is(err, model.ErrOrderConflict) || errors.Is(err, model.ErrVersionConflict) {
+			return problemResponse(problem{Type: problemConflict, Title: "Order conflicts with the stored order", Status: http.StatusConflict, Detail: err.Error()})
+		}
+		h.l.Error("failed to create order", "uid", order.OrderUID, "error", err)
+		return problemResponse(problem{Type: problemInternal, Title: "Internal server error", Status: http.StatusInternalServerError})
+	}
+
+	result := ingestResult{OrderUID: order.OrderUID, Result: string(status), Version: order.Version, Warnings: warnings}
+	switch status {
+	case model.SaveInserted:
+		resp := jsonResponse(http.StatusCreated, result)
+		resp.Headers = map[string]string{"Location": "/order/" + order.OrderUID}
+		return resp
+	case model.SaveIgnored:
+		return problemResponse(problem{Type: problemConflict, Title: "Order conflicts with the stored order", Status: http.StatusConflict, Detail: model.ErrOrderConflict.Error()})
+	case model.SaveErased:
+		return problemResponse(problem{Type: problemErased, Title: "Order was erased and cannot be stored again", Status: http.StatusGone})
+	default:
+		return jsonResponse(http.StatusOK, result)
+	}
+}
+
+func (h *Handler) createOrders(ctx context.Context, body []byte) response {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return problemResponse(problem{Type: problemInvalidBody, Title: "Expected a JSON array of orders", Status: http.StatusBadRequest, Detail: err.Error()})
+	}
+	if len(raw) == 0 || len(raw) > h.batchLimit {
+		return problemResponse(problem{Type: problemInvalidBody, Title: "Invalid batch size", Status: http.StatusBadRequest})
+	}
+
+	results := make([]ingestResult, len(raw))
+	valid := make([]*model.Order, 0, len(raw))
+	positions := make([]int, 0, len(raw))
+	for i, data := range raw {
+		var order model.Order
+		if err := json.Unmarshal(data, &order); err != nil {
+			results[i] = ingestResult{Result: resultInvalid, Error: err.Error()}
+			continue
+		}
+		results[i].OrderUID = order.OrderUID
+		warnings, err := validator.Validate(&order)
+		if err != nil {
+			results[i].Result = resultInvalid
+			var verr *validator.Error
+			if errors.As(err, &verr) {
+				results[i].Errors = verr.Violations
+			} else {
+				results[i].Error = err.Error()
+			}
+			continue
+		}
+		if len(warnings) > 0 {
+			h.l.Warn("order accepted with warnings", "uid", order.OrderUID, "violations", warnings)
+			results[i].Warnings = warnings
+		}
+
+		if h.publisher != nil {
+			results[i].Result = resultAccepted
+			if err := h.publisher.SendMessage(ctx, order.OrderUID, data); err != nil {
+				h.l.Error("failed to publish order", "uid", order.OrderUID, "error", err)
+				results[i].Result = string(model.SaveFailed)
+				results[i].Error = "order could not be queued"
+			}
+			continue
+		}
+		valid = append(valid, &order)
+		positions = append(positions, i)
+	}
+
+	if len(valid) > 0 {
+		saved, err := h.service.CreateOrders(ctx, valid)
+		if err != nil {
+			h.l.Error("failed to create orders", "error", err)
+			return problemResponse(problem{Type: problemInternal, Title: "Internal server error", Status: http.StatusInternalServerError})
+		}
+		for j, res := range saved {
+			i := positions[j]
+			results[i].Result = string(res.Status)
+			results[i].Version = valid[j].Version
+			if res.Err != nil {
+				results[i].Error = res.Err.Error()
+			}
+		}
+	}
+
+	return jsonResponse(http.StatusOK, batchResponse{Results: results})
+}
+
+// idempotent reads the request body and, when the request carries an
+// Idempotency-Key, replays the stored response for the same key and payload.
+// The key is reserved atomically before the request is processed, so
+// concurrent requests with the same key are not processed twice. Server
+// errors release the key so that the client can retry them.
+func (h *Handler) idempotent(limit int64, process func(ctx context.Context, body []byte) response) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				h.write(w, problemResponse(problem{Type: problemTooLarge, Title: "Request body is too large", Status: http.StatusRequestEntityTooLarge}))
+				return
+			}
+			h.write(w, problemResponse(problem{Type: problemInvalidBody, Title: "Failed to read request body", Status: http.StatusBadRequest}))
+			return
+		}
+
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" || h.idempotency == nil {
+			h.write(w, process(r.Context(), body))
+			return
+		}
+
+		sum := sha256.Sum256(body)
+		hash := hex.EncodeToString(sum[:])
+
+		storeKey := IdempotencyPrefix + key
+		reserved, err := h.idempotency.SetNX(r.Context(), storeKey, idempotentResponse{Hash: hash, Pending: true}, idempotencyLockTTL)
+		if err != nil {
+			// Without a reservation a retry could create the order twice, so the
+			// request is refused rather than processed unprotected.
+			h.l.Error("failed to reserve idempotency key", "error", err)
+			resp := problemResponse(problem{
+				Type:   problemUnavailable,
+				Title:  "Idempotency-Key cannot be checked right now",
+				Status: http.StatusServiceUnavailable,
+			})
+			resp.Headers = map[string]string{"Retry-After": "5"}
+			h.write(w, resp)
+			return
+		}
+		if !reserved {
+			h.write(w, h.replay(r.Context(), storeKey, hash))
+			return
+		}
+
+		resp := process(r.Context(), body)
+		if resp.Status < http.StatusInternalServerError {
+			stored := idempotentResponse{Hash: hash, Response: resp}
+			if err := h.idempotency.Set(r.Context(), storeKey, stored, h.idempotencyTTL); err != nil {
+				h.l.Error("failed to store idempotency key", "error", err)
+			}
+		} else if err := h.idempotency.Delete(r.Context(), storeKey); err != nil {
+			h.l.Error("failed to release idempotency key", "error", err)
+		}
+		h.write(w, resp)
+	}
+}
+
+// replay answers a request whose Idempotency-Key is already taken: with the
+// stored response for the same payload, 422 for a different payload, or 409
+// while the first request is still being processed.
+func (h *Handler) replay(ctx context.Context, key, hash string) response {
+	inProgress := problemResponse(problem{
+		Type:   problemInProgress,
+		Title:  "A request with this Idempotency-Key is still being processed",
+		Status: http.StatusConflict,
+	})
+	inProgress.Headers = map[string]string{"Retry-After": "1"}
+
+	var prev idempotentResponse
+	err := h.idempotency.Get(ctx, key, &prev)
+	switch {
+	case errors.Is(err, redis.ErrCacheMiss):
+		return inProgress
+	case err != nil:
+		h.l.Error("failed to read idempotency key", "error", err)
+		return problemResponse(problem{Type: problemInternal, Title: "Internal server error", Status: http.StatusInternalServerError})
+	case prev.Hash != hash:
+		return problemResponse(problem{
+			Type:   problemIdempotency,
+			Title:  "Idempotency-Key was already used with a different payload",
+			Status: http.StatusUnprocessableEntity,
+		})
+	case prev.Pending:
+		return inProgress
+	}
+
+	resp := prev.Response
+	headers := make(map[string]string, len(resp.Headers)+1)
+	maps.Copy(headers, resp.Headers)
+	headers["Idempotent-Replayed"] = "true"
+	resp.Headers = headers
+	return resp
+}
+
+func invalidResponse(err error) response {
+	var verr *validator.Error
+	if errors.As(err, &verr) {
+		return problemResponse(validationProblem(verr))
+	}
+	return problemResponse(problem{Type: problemValidation, Title: "Order validation failed", Status: http.StatusUnprocessableEntity, Detail: err.Error()})
+}
+
+func jsonResponse(status int, v any) response {
+	body, err := json.Marshal(v)
+	if err != nil {
+		return problemResponse(problem{Type: problemInternal, Title: "Internal server error", Status: http.StatusInternalServerError})
+	}
+	return response{Status: status, ContentType: "application/json", Body: body}
+}
+
+func problemResponse(p problem) response {
+	body, _ := json.Marshal(p)
+	return response{Status: p.Status, ContentType: "application/problem+json", Body: body}
+}
+
+func (h *Handler) write(w http.ResponseWriter, resp response) {
+	for k, v := range resp.Headers {
+		w.Header().Set(k, v)
+	}
+	w.Header().Set("Content-Type", resp.ContentType)
+	w.WriteHeader(resp.Status)
+	if _, err := w.Write(append(resp.Body, '\n')); err != nil {
+		h.l.Error("failed to write response", "error", err)
+	}
+}
@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/MikebangSfilya/wb/internal/repository/redis"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockService struct {
+	OrderService
+	mock.Mock
+}
+
+func (m *MockService) CreateOrder(ctx context.Context, order *model.Order) (model.SaveStatus, error) {
+	args := m.Called(ctx, order)
+	return args.Get(0).(model.SaveStatus), args.Error(1)
+}
+
+func (m *MockService) CreateOrders(ctx context.Context, orders []*model.Order) ([]model.SaveResult, error) {
+	args := m.Called(ctx, orders)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SaveResult), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) SendMessage(ctx context.Context, key string, value []byte, _ ...kafka.Header) error {
+	args := m.Called(ctx, key, value)
+	return args.Error(0)
+}
+
+type memoryStore map[string][]byte
+
+func (s memoryStore) Set(_ context.Context, key string, value any, _ time.Duration) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	s[key] = data
+	return nil
+}
+
+func (s memoryStore) SetNX(ctx context.Context, key string, value any, ttl time.Duration) (bool, error) {
+	if _, ok := s[key]; ok {
+		return false, nil
+	}
+	return true, s.Set(ctx, key, value, ttl)
+}
+
+func (s memoryStore) Delete(_ context.Context, key string) error {
+	delete(s, key)
+	return nil
+}
+
+func (s memoryStore) Get(_ context.Context, key string, dest any) error {
+	data, ok := s[key]
+	if !ok {
+		return redis.ErrCacheMiss
+	}
+	return json.Unmarshal(data, dest)
+}
+
+func testOrder() *model.Order {
+	return &model.Order{
+		OrderUID:        "034",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "034",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
+
+func postJSON(t *testing.T, h http.HandlerFunc, body any, key string) *httptest.ResponseRecorder {
+	t.Helper()
+	data, err := json.Marshal(body)
+	require.NoError(t, err)
+	req := httptest.NewRequest(http.MethodPost, "/orders", bytes.NewReader(data))
+	if key != "" {
+		req.Header.Set(idempotencyHeader, key)
+	}
+	rec := httptest.NewRecorder()
+	h(rec, req)
+	return rec
+}
+
+func TestHandler_CreateOrder(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	invalid := testOrder()
+	invalid.TrackNumber = ""
+
+	tests := []struct {
+		name        string
+		order       *model.Order
+		status      model.SaveStatus
+		err         error
+		wantCode    int
+		wantType    string
+		wantCreated bool
+	}{
+		{name: "inserted", order: testOrder(), status: model.SaveInserted, wantCode: http.StatusCreated, wantCreated: true},
+		{name: "duplicate", order: testOrder(), status: model.SaveDuplicate, wantCode: http.StatusOK},
+		{name: "ignored", order: testOrder(), status: model.SaveIgnored, wantCode: http.StatusConflict, wantType: problemConflict},
+		{name: "erased", order: testOrder(), status: model.SaveErased, wantCode: http.StatusGone, wantType: problemErased},
+		{name: "rejected", order: testOrder(), status: model.SaveConflict, err: model.ErrOrderConflict, wantCode: http.StatusConflict, wantType: problemConflict},
+		{name: "version conflict", order: testOrder(), status: model.SaveFailed, err: model.ErrVersionConflict, wantCode: http.StatusConflict, wantType: problemConflict},
+		{name: "invalid", order: invalid, wantCode: http.StatusUnprocessableEntity, wantType: problemValidation},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := new(MockService)
+			if tt.wantType != problemValidation {
+				svc.On("CreateOrder", mock.Anything, mock.Anything).Return(tt.status, tt.err).Once()
+			}
+
+			rec := postJSON(t, New(l, svc).CreateOrder(), tt.order, "")
+
+			assert.Equal(t, tt.wantCode, rec.Code)
+			if tt.wantType != "" {
+				var got problem
+				require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+				assert.Equal(t, tt.wantType, got.Type)
+			}
+			if tt.wantCreated {
+				assert.Equal(t, "/order/034", rec.Header().Get("Location"))
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}
+
+func TestHandler_CreateOrder_Publisher(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc := new(MockService)
+	pub := new(MockPublisher)
+	pub.On("SendMessage", mock.Anything, "034", mock.Anything).Return(nil).Once()
+
+	rec := postJSON(t, New(l, svc, WithPublisher(pub)).CreateOrder(), testOrder(), "")
+
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	svc.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+	pub.AssertExpectations(t)
+}
+
+func TestHandler_CreateOrder_Warnings(t *testing.T) {
+	validator.Init(validator.WithSeverity(validator.RuleTransactionOwner, validator.SeverityWarn))
+	defer validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	order := testOrder()
+	order.Payment.Transaction = "other"
+
+	svc := new(MockService)
+	svc.On("CreateOrder", mock.Anything, mock.Anything).Return(model.SaveInserted, nil).Once()
+
+	rec := postJSON(t, New(l, svc).CreateOrder(), order, "")
+	require.Equal(t, http.StatusCreated, rec.Code)
+
+	var got ingestResult
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got.Warnings, 1)
+	assert.Equal(t, validator.RuleTransactionOwner, got.Warnings[0].Rule)
+	assert.Equal(t, validator.SeverityWarn, got.Warnings[0].Severity)
+	svc.AssertExpectations(t)
+}
+
+func TestHandler_CreateOrder_Idempotency(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc := new(MockService)
+	svc.On("CreateOrder", mock.Anything, mock.Anything).Return(model.SaveInserted, nil).Once()
+	h := New(l, svc, WithIdempotency(memoryStore{}, time.Hour)).CreateOrder()
+
+	first := postJSON(t, h, testOrder(), "key-1")
+	require.Equal(t, http.StatusCreated, first.Code)
+
+	replay := postJSON(t, h, testOrder(), "key-1")
+	assert.Equal(t, http.StatusCreated, replay.Code)
+	assert.Equal(t, "true", replay.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, "/order/034", replay.Header().Get("Location"))
+	assert.Equal(t, first.Body.String(), replay.Body.String())
+
+	changed := testOrder()
+	changed.Delivery.City = "Haifa"
+	reused := postJSON(t, h, changed, "key-1")
+	assert.Equal(t, http.StatusUnprocessableEntity, reused.Code)
+
+	svc.AssertExpectations(t)
+}
+
+func TestHandler_CreateOrder_IdempotencyReserved(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	body, err := json.Marshal(testOrder())
+	require.NoError(t, err)
+	sum := sha256.Sum256(body)
+
+	store := memoryStore{}
+	require.NoError(t, store.Set(context.Background(), IdempotencyPrefix+"key-1",
+		idempotentResponse{Hash: hex.EncodeToString(sum[:]), Pending: true}, time.Minute))
+
+	svc := new(MockService)
+	h := New(l, svc, WithIdempotency(store, time.Hour)).CreateOrder()
+
+	rec := postJSON(t, h, testOrder(), "key-1")
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get("Retry-After"))
+	svc.AssertNotCalled(t, "CreateOrder", mock.Anything, mock.Anything)
+}
+
+func TestHandler_CreateOrder_IdempotencyReleasedOnError(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc := new(MockService)
+	svc.On("CreateOrder", mock.Anything, mock.Anything).Return(model.SaveFailed, errors.New("db down")).Once()
+	svc.On("CreateOrder", mock.Anything, mock.Anything).Return(model.SaveInserted, nil).Once()
+	h := New(l, svc, WithIdempotency(memoryStore{}, time.Hour)).CreateOrder()
+
+	failed := postJSON(t, h, testOrder(), "key-1")
+	require.Equal(t, http.StatusInternalServerError, failed.Code)
+
+	retried := postJSON(t, h, testOrder(), "key-1")
+	assert.Equal(t, http.StatusCreated, retried.Code)
+	svc.AssertExpectations(t)
+}
+
+// downStore fails like Redis does while degraded.
+type downStore struct{ memoryStore }
+
+func (downStore) SetNX(context.Context, string, any, time.Duration) (bool, error) {
+	return false, redis.ErrUnavailable
+}
+
+func TestHandler_CreateOrder_IdempotencyUnavailable(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	svc := new(MockService)
+	h := New(l, svc, WithIdempotency(downStore{memoryStore{}}, time.Hour)).CreateOrder()
+
+	rec := postJSON(t, h, testOrder(), "key-1")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "5", rec.Header().Get("Retry-After"))
+
+	// Requests without the header do not depend on Redis.
+	svc.On("CreateOrder", mock.Anything, mock.Anything).Return(model.SaveInserted, nil).Once()
+	rec = postJSON(t, h, testOrder(), "")
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	svc.AssertExpectations(t)
+}
+
+func TestHandler_CreateOrders(t *testing.T) {
+	validator.Init()
+	l := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	second := testOrder()
+	second.OrderUID = "035"
+	second.Payment.Transaction = "035"
+	invalid := testOrder()
+	invalid.OrderUID = "036"
+	invalid.TrackNumber = ""
+
+	svc := new(MockService)
+	svc.On("CreateOrders", mock.Anything, mock.MatchedBy(func(orders []*model.Order) bool {
+		return len(orders) == 2
+	})).Return([]model.SaveResult{
+		{OrderUID: "034", Status: model.SaveInserted},
+		{OrderUID: "035", Status: model.SaveConflict, Err: model.ErrOrderConflict},
+	}, nil).Once()
+
+	rec := postJSON(t, New(l, svc).CreateOrders(), []*model.Order{testOrder(), invalid, second}, "")
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var got batchResponse
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+	require.Len(t, got.Results, 3)
+	assert.Equal(t, string(model.SaveInserted), got.Results[0].Result)
+	assert.Equal(t, resultInvalid, got.Results[1].Result)
+	assert.NotEmpty(t, got.Results[1].Errors)
+	assert.Equal(t, string(model.SaveConflict), got.Results[2].Result)
+	assert.Equal(t, "035", got.Results[2].OrderUID)
+	svc.AssertExpectations(t)
+
+	tooMany := New(l, svc, WithBatchLimit(1)).CreateOrders()
+	rec = postJSON(t, tooMany, []*model.Order{testOrder(), second}, "")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+}
@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+)
+
+func (h *Handler) ListOrders() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		filter, err := parseOrderFilter(r.URL.Query())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		page, err := h.service.ListOrders(r.Context(), filter)
+		if err != nil {
+			if errors.Is(err, model.ErrInvalidCursor) {
+				http.Error(w, "invalid cursor", http.StatusBadRequest)
+				return
+			}
+			h.l.Error("failed to list orders", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(page); err != nil {
+			h.l.Error("failed to encode response", "error", err)
+			return
+		}
+	}
+}
+
+func parseOrderFilter(q url.Values) (model.OrderFilter, error) {
+	f := model.OrderFilter{
+		CustomerID:      q.Get("customer_id"),
+		TrackNumber:     q.Get("track_number"),
+		DeliveryService: q.Get("delivery_service"),
+		PaymentProvider: q.Get("payment_provider"),
+		PaymentBank:     q.Get("payment_bank"),
+		ItemBrand:       q.Get("brand"),
+		Sort:            model.OrderSort(q.Get("sort")),
+		Cursor:          q.Get("cursor"),
+	}
+
+	var err error
+	if v := q.Get("created_from"); v != "" {
+		if f.CreatedFrom, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("invalid created_from: expected RFC3339 time")
+		}
+	}
+	if v := q.Get("created_to"); v != "" {
+		if f.CreatedTo, err = time.Parse(time.RFC3339, v); err != nil {
+			return f, errors.New("invalid created_to: expected RFC3339 time")
+		}
+	}
+	if v := q.Get("nm_id"); v != "" {
+		if f.ItemNmID, err = strconv.Atoi(v); err != nil || f.ItemNmID <= 0 {
+			return f, errors.New("invalid nm_id")
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		if f.Limit, err = strconv.Atoi(v); err != nil || f.Limit <= 0 {
+			return f, errors.New("invalid limit")
+		}
+	}
+	if f.Sort != "" && !f.Sort.Valid() {
+		return f, errors.New("invalid sort: use date_created, -date_created, amount or -amount")
+	}
+
+	return f, nil
+}
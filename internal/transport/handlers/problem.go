@@ -0,0 +1,32 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+)
+
+const problemValidation = "/problems/validation-error"
+
+var validationTitles = map[string]string{
+	validator.LocaleEN: "Order validation failed",
+	validator.LocaleRU: "Заказ не прошёл проверку",
+}
+
+// problem is an RFC 9457 problem document.
+type problem struct {
+	Type   string                `json:"type"`
+	Title  string                `json:"title"`
+	Status int                   `json:"status"`
+	Detail string                `json:"detail,omitempty"`
+	Errors []validator.Violation `json:"errors,omitempty"`
+}
+
+func validationProblem(err *validator.Error) problem {
+	return problem{
+		Type:   problemValidation,
+		Title:  validationTitles[err.Locale],
+		Status: http.StatusUnprocessableEntity,
+		Errors: err.Violations,
+	}
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidationProblem(t *testing.T) {
+	validator.Init()
+
+	tests := []struct {
+		name        string
+		locale      string
+		wantTitle   string
+		wantMessage string
+	}{
+		{name: "en", locale: "en", wantTitle: "Order validation failed", wantMessage: "is required"},
+		{name: "ru", locale: "ru", wantTitle: "Заказ не прошёл проверку", wantMessage: "обязательное поле"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.Validate(&model.Order{OrderUID: "034", Locale: tt.locale})
+			var verr *validator.Error
+			require.True(t, errors.As(err, &verr))
+
+			h := New(slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+			rec := httptest.NewRecorder()
+			h.write(rec, invalidResponse(verr))
+
+			assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+			assert.Equal(t, "application/problem+json", rec.Header().Get("Content-Type"))
+
+			var got problem
+			require.NoError(t, json.NewDecoder(rec.Body).Decode(&got))
+			assert.Equal(t, problemValidation, got.Type)
+			assert.Equal(t, tt.wantTitle, got.Title)
+			require.NotEmpty(t, got.Errors)
+			assert.Equal(t, "track_number", got.Errors[0].Field)
+			assert.Equal(t, "required", got.Errors[0].Rule)
+			assert.Equal(t, tt.wantMessage, got.Errors[0].Message)
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/go-chi/chi/v5"
+)
+
+type updateStatusRequest struct {
+	Status model.OrderStatus `json:"status"`
+	Reason string            `json:"reason"`
+}
+
+func (h *Handler) UpdateOrderStatus() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		var req updateStatusRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "invalid request body", http.StatusBadRequest)
+			return
+		}
+
+		change, err := h.service.UpdateOrderStatus(r.Context(), id, req.Status, req.Reason)
+		if err != nil {
+			switch {
+			case errors.Is(err, model.ErrNotFound):
+				http.Error(w, "order not found", http.StatusNotFound)
+			case errors.Is(err, model.ErrInvalidStatus):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			case errors.Is(err, model.ErrInvalidTransition), errors.Is(err, model.ErrStatusConflict):
+				http.Error(w, err.Error(), http.StatusConflict)
+			default:
+				h.l.Error("failed to update order status", "error", err)
+				http.Error(w, "internal server error", http.StatusInternalServerError)
+			}
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(change); err != nil {
+			h.l.Error("failed to encode response", "error", err)
+			return
+		}
+	}
+}
+
+func (h *Handler) GetStatusHistory() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		id := chi.URLParam(r, "id")
+		if id == "" {
+			http.Error(w, "id is required", http.StatusBadRequest)
+			return
+		}
+
+		history, err := h.service.GetStatusHistory(r.Context(), id)
+		if err != nil {
+			if errors.Is(err, model.ErrNotFound) {
+				http.Error(w, "order not found", http.StatusNotFound)
+				return
+			}
+			h.l.Error("failed to get status history", "error", err)
+			http.Error(w, "internal server error", http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(history); err != nil {
+			h.l.Error("failed to encode response", "error", err)
+			return
+		}
+	}
+}
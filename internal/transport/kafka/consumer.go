@@ -2,10 +2,15 @@ package kafka
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"hash/fnv"
 	"io"
 	"log/slog"
+	"strconv"
+	"sync"
 	"time"
 
 	"github.com/MikebangSfilya/wb/internal/lib/validator"
@@ -15,21 +20,52 @@ import (
 )
 
 const (
-	baseDelay   = 1 * time.Second
-	maxDelay    = 15 * time.Second
-	maxAttempts = 15
+	baseDelay       = 1 * time.Second
+	maxDelay        = 15 * time.Second
+	maxAttempts     = 15
+	commitInterval  = 1 * time.Second
+	commitTimeout   = 5 * time.Second
+	workerQueueSize = 64
 )
 
 type Service interface {
-	CreateOrder(ctx context.Context, order *model.Order) error
+	IngestOrder(ctx context.Context, order *model.Order, src model.MessageSource) error
+	IngestOrders(ctx context.Context, orders []*model.Order, srcs []model.MessageSource) ([]model.SaveResult, error)
 }
 type Consumer struct {
-	reader  *kafka.Reader
-	service Service
-	l       *slog.Logger
+	reader       *kafka.Reader
+	service      Service
+	statuses     StatusService
+	process      func(ctx context.Context, m kafka.Message) *failure
+	processBatch func(ctx context.Context, msgs []kafka.Message) []*failure
+	batchSize    int
+	dlq          DeadLetterPublisher
+	workers      int
+	l            *slog.Logger
 }
 
-func NewConsumer(l *slog.Logger, brokers []string, group, topic string, service Service) *Consumer {
+type ConsumerOption func(*Consumer)
+
+// WithBatchSize lets each worker save up to n already fetched messages in one
+// transaction. The default of 1 saves every message on its own.
+func WithBatchSize(n int) ConsumerOption {
+	return func(c *Consumer) {
+		c.batchSize = max(n, 1)
+	}
+}
+
+func NewConsumer(l *slog.Logger, brokers []string, group, topic string, workers int, service Service, dlq DeadLetterPublisher, opts ...ConsumerOption) *Consumer {
+	c := newConsumer(l, brokers, group, topic, workers, dlq)
+	c.service = service
+	c.process = c.processWithRetry
+	c.processBatch = c.processBatchWithRetry
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+func newConsumer(l *slog.Logger, brokers []string, group, topic string, workers int, dlq DeadLetterPublisher) *Consumer {
 	return &Consumer{
 		reader: kafka.NewReader(kafka.ReaderConfig{
 			Brokers:     brokers,
@@ -40,78 +76,325 @@ func NewConsumer(l *slog.Logger, brokers []string, group, topic string, service
 			MaxWait:     1 * time.Second,
 			StartOffset: kafka.FirstOffset,
 		}),
-		service: service,
-		l:       l,
+		dlq:       dlq,
+		workers:   max(workers, 1),
+		batchSize: 1,
+		l:         l.With("topic", topic),
 	}
 }
 
 func (c *Consumer) Start(ctx context.Context) error {
-	c.l.Info("kafka consumer started")
+	c.l.Info("kafka consumer started", "workers", c.workers)
 	defer c.l.Info("kafka consumer stopped")
 
+	tracker := newOffsetTracker()
+
+	var wg sync.WaitGroup
+	queues := make([]chan fetchedMessage, c.workers)
+	for i := range queues {
+		queues[i] = make(chan fetchedMessage, workerQueueSize)
+		wg.Add(1)
+		go func(queue <-chan fetchedMessage) {
+			defer wg.Done()
+			if c.processBatch != nil && c.batchSize > 1 {
+				c.work(ctx, queue, tracker)
+				return
+			}
+			for m := range queue {
+				if c.handle(ctx, m.Message) {
+					tracker.done(m)
+				}
+			}
+		}(queues[i])
+	}
+
+	commitDone := make(chan struct{})
+	go func() {
+		defer close(commitDone)
+		c.commitLoop(ctx, tracker)
+	}()
+
+	c.fetch(ctx, tracker, queues)
+
+	for _, queue := range queues {
+		close(queue)
+	}
+	wg.Wait()
+	<-commitDone
+
+	commitCtx, cancel := context.WithTimeout(context.Background(), commitTimeout)
+	defer cancel()
+	c.commit(commitCtx, tracker)
+
+	return nil
+}
+
+func (c *Consumer) fetch(ctx context.Context, tracker *offsetTracker, queues []chan fetchedMessage) {
 	for {
 		m, err := c.reader.FetchMessage(ctx)
 		if err != nil {
-			if errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) {
-				return nil
+			if errors.Is(err, context.Canceled) || errors.Is(err, io.EOF) || ctx.Err() != nil {
+				return
 			}
 			c.l.Error("failed to fetch message", "error", err.Error())
 			time.Sleep(1 * time.Second)
 			continue
 		}
 
-		c.processWithRetry(ctx, m)
+		// The reader does not report revoked partitions, only that the group
+		// rebalanced since the last call.
+		if c.reader.Stats().Rebalances > 0 {
+			tracker.reset()
+		}
+		f := tracker.add(m)
+
+		select {
+		case queues[route(m, len(queues))] <- f:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func route(m kafka.Message, workers int) int {
+	h := fnv.New32a()
+	if len(m.Key) > 0 {
+		_, _ = h.Write(m.Key)
+	} else {
+		_, _ = h.Write([]byte(strconv.Itoa(m.Partition)))
+	}
+	return int(h.Sum32() % uint32(workers))
+}
 
-		if err := c.reader.CommitMessages(ctx, m); err != nil {
-			c.l.Error("failed to commit message", "error", err)
+// work handles the queue in batches: it waits for one message and adds
+// whatever else is already queued, up to batchSize.
+func (c *Consumer) work(ctx context.Context, queue <-chan fetchedMessage, tracker *offsetTracker) {
+	batch := make([]fetchedMessage, 0, c.batchSize)
+	msgs := make([]kafka.Message, 0, c.batchSize)
+	for m := range queue {
+		batch = append(batch[:0], m)
+	drain:
+		for len(batch) < c.batchSize {
+			select {
+			case m, ok := <-queue:
+				if !ok {
+					break drain
+				}
+				batch = append(batch, m)
+			default:
+				break drain
+			}
+		}
+
+		msgs = msgs[:0]
+		for _, m := range batch {
+			msgs = append(msgs, m.Message)
+		}
+		for _, m := range batch[:c.handleBatch(ctx, msgs)] {
+			tracker.done(m)
 		}
-		c.l.Debug("message committed", "message", string(m.Key))
 	}
+}
 
+// handleBatch processes the messages and returns how many of them, from the
+// start, are done.
+func (c *Consumer) handleBatch(ctx context.Context, msgs []kafka.Message) int {
+	failures := c.processBatch(ctx, msgs)
+	for i, m := range msgs {
+		if f := failures[i]; f != nil {
+			if err := c.deadLetter(ctx, m, f); err != nil {
+				return i
+			}
+		}
+		if ctx.Err() != nil {
+			return i
+		}
+	}
+	return len(msgs)
+}
+
+func (c *Consumer) handle(ctx context.Context, m kafka.Message) bool {
+	if f := c.process(ctx, m); f != nil {
+		if err := c.deadLetter(ctx, m, f); err != nil {
+			return false
+		}
+	}
+	return ctx.Err() == nil
 }
 
-func (c *Consumer) processWithRetry(ctx context.Context, m kafka.Message) {
+func (c *Consumer) commitLoop(ctx context.Context, tracker *offsetTracker) {
+	ticker := time.NewTicker(commitInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.commit(ctx, tracker)
+		}
+	}
+}
+
+func (c *Consumer) commit(ctx context.Context, tracker *offsetTracker) {
+	msgs := tracker.committable()
+	if len(msgs) == 0 {
+		return
+	}
+
+	if err := c.reader.CommitMessages(ctx, msgs...); err != nil {
+		c.l.Error("failed to commit messages", "error", err)
+		return
+	}
+	for _, m := range msgs {
+		c.l.Debug("offset committed", "partition", m.Partition, "offset", m.Offset)
+	}
+}
+
+func (c *Consumer) processWithRetry(ctx context.Context, m kafka.Message) *failure {
+	order, f := c.decode(m)
+	if f != nil {
+		return f
+	}
+	return c.persist(ctx, order, messageSource(m))
+}
+
+// processBatchWithRetry saves the valid orders of msgs in one transaction.
+// Orders that fail on their own are retried one by one.
+func (c *Consumer) processBatchWithRetry(ctx context.Context, msgs []kafka.Message) []*failure {
+	failures := make([]*failure, len(msgs))
+	orders := make([]*model.Order, 0, len(msgs))
+	srcs := make([]model.MessageSource, 0, len(msgs))
+	idx := make([]int, 0, len(msgs))
+	for i, m := range msgs {
+		order, f := c.decode(m)
+		if f != nil {
+			failures[i] = f
+			continue
+		}
+		orders = append(orders, order)
+		srcs = append(srcs, messageSource(m))
+		idx = append(idx, i)
+	}
+	if len(orders) == 0 {
+		return failures
+	}
+
+	var results []model.SaveResult
+	f := c.retry(ctx, func(ctx context.Context) error {
+		var err error
+		results, err = c.service.IngestOrders(ctx, orders, srcs)
+		return err
+	})
+	if f != nil {
+		for _, i := range idx {
+			failures[i] = f
+		}
+		return failures
+	}
+	if results == nil {
+		return failures
+	}
+
+	for j, res := range results {
+		switch {
+		case res.Err == nil:
+		case errors.Is(res.Err, model.ErrOrderConflict) || errors.Is(res.Err, model.ErrVersionConflict):
+			c.l.Warn("order conflicts with the stored version", "uid", res.OrderUID, "error", res.Err)
+			failures[idx[j]] = &failure{stage: StageConflict, err: res.Err, attempts: 1}
+		default:
+			failures[idx[j]] = c.persist(ctx, orders[j], srcs[j])
+		}
+	}
+	return failures
+}
+
+func (c *Consumer) decode(m kafka.Message) (*model.Order, *failure) {
 	var order model.Order
 	if err := json.Unmarshal(m.Value, &order); err != nil {
-		c.l.Error("skipping invalid json", "error", err, "offset", m.Offset)
-		return
+		c.l.Error("invalid json", "error", err, "offset", m.Offset)
+		return nil, &failure{stage: StageDecode, err: err, attempts: 1}
+	}
+
+	warnings, err := validator.Validate(&order)
+	if err != nil {
+		c.logInvalid("invalid order", order.OrderUID, err)
+		return nil, &failure{stage: StageValidate, err: err, attempts: 1}
+	}
+	if len(warnings) > 0 {
+		c.l.Warn("order accepted with warnings", "uid", order.OrderUID, "violations", warnings)
+	}
+	return &order, nil
+}
+
+func (c *Consumer) persist(ctx context.Context, order *model.Order, src model.MessageSource) *failure {
+	var conflict error
+	f := c.retry(ctx, func(ctx context.Context) error {
+		err := c.service.IngestOrder(ctx, order, src)
+		if errors.Is(err, model.ErrOrderConflict) || errors.Is(err, model.ErrVersionConflict) {
+			conflict = err
+			return nil
+		}
+		return err
+	})
+	if conflict != nil {
+		c.l.Warn("order conflicts with the stored version", "uid", order.OrderUID, "error", conflict)
+		return &failure{stage: StageConflict, err: conflict, attempts: 1}
 	}
+	return f
+}
 
-	if err := validator.Validate(&order); err != nil {
-		c.l.Error("skipping invalid order", "error", err)
+func (c *Consumer) logInvalid(msg, uid string, err error) {
+	var verr *validator.Error
+	if errors.As(err, &verr) {
+		c.l.Error(msg, "uid", uid, "locale", verr.Locale, "violations", verr.Violations)
 		return
 	}
+	c.l.Error(msg, "uid", uid, "error", err)
+}
+
+func messageSource(m kafka.Message) model.MessageSource {
+	sum := sha256.Sum256(m.Value)
+	return model.MessageSource{
+		Topic:       m.Topic,
+		Partition:   m.Partition,
+		Offset:      m.Offset,
+		PayloadHash: hex.EncodeToString(sum[:]),
+	}
+}
 
+func (c *Consumer) retry(ctx context.Context, fn func(ctx context.Context) error) *failure {
 	currentDelay := baseDelay
 	attempt := 0
 	for {
 		if ctx.Err() != nil {
-			return
+			return nil
 		}
 
-		err := c.service.CreateOrder(ctx, &order)
+		err := fn(ctx)
 
 		if err == nil {
-			return
+			return nil
 		}
 
 		attempt++
-		c.l.Warn("failed to create order, retrying",
+		if attempt >= maxAttempts {
+			c.l.Error("too many attempts, end this", "error", err, "attempt", attempt)
+			return &failure{stage: StagePersist, err: err, attempts: attempt}
+		}
+
+		c.l.Warn("failed to process message, retrying",
 			"error", err,
 			"attempt", attempt)
 
 		select {
 		case <-ctx.Done():
-			return
+			return nil
 		case <-time.After(currentDelay):
 			currentDelay *= 2
 			if currentDelay > maxDelay {
 				currentDelay = maxDelay
 			}
-			if attempt >= maxAttempts {
-				c.l.Error("too many attempts, end this")
-				return
-			}
 		}
 
 	}
@@ -0,0 +1,267 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockService struct {
+	mock.Mock
+}
+
+func (m *MockService) IngestOrder(ctx context.Context, order *model.Order, src model.MessageSource) error {
+	args := m.Called(ctx, order, src)
+	return args.Error(0)
+}
+
+func (m *MockService) IngestOrders(ctx context.Context, orders []*model.Order, srcs []model.MessageSource) ([]model.SaveResult, error) {
+	args := m.Called(ctx, orders, srcs)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.SaveResult), args.Error(1)
+}
+
+type MockPublisher struct {
+	mock.Mock
+}
+
+func (m *MockPublisher) SendMessage(ctx context.Context, key string, value []byte, headers ...kafka.Header) error {
+	args := m.Called(ctx, key, value, headers)
+	return args.Error(0)
+}
+
+func header(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+	return ""
+}
+
+func TestConsumer_DeadLetter(t *testing.T) {
+	validator.Init()
+
+	tests := []struct {
+		name      string
+		value     []byte
+		wantStage string
+	}{
+		{
+			name:      "invalid_json",
+			value:     []byte(`{"order_uid":`),
+			wantStage: StageDecode,
+		},
+		{
+			name:      "invalid_order",
+			value:     []byte(`{"order_uid":"034"}`),
+			wantStage: StageValidate,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &MockService{}
+			pub := &MockPublisher{}
+			c := &Consumer{
+				service: svc,
+				dlq:     pub,
+				l:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			m := kafka.Message{
+				Topic:     "orders",
+				Partition: 2,
+				Offset:    42,
+				Key:       []byte("034"),
+				Value:     tt.value,
+			}
+
+			var sent []kafka.Header
+			pub.On("SendMessage", mock.Anything, "034", tt.value, mock.Anything).
+				Run(func(args mock.Arguments) { sent = args.Get(3).([]kafka.Header) }).
+				Return(nil)
+
+			f := c.processWithRetry(context.Background(), m)
+			require.NotNil(t, f)
+			assert.Equal(t, tt.wantStage, f.stage)
+
+			require.NoError(t, c.deadLetter(context.Background(), m, f))
+
+			assert.Equal(t, tt.wantStage, header(sent, HeaderStage))
+			assert.Equal(t, "1", header(sent, HeaderAttempts))
+			assert.Equal(t, "orders", header(sent, HeaderSourceTopic))
+			assert.Equal(t, strconv.Itoa(m.Partition), header(sent, HeaderSourcePartition))
+			assert.Equal(t, "42", header(sent, HeaderSourceOffset))
+			assert.NotEmpty(t, header(sent, HeaderReason))
+			_, err := time.Parse(time.RFC3339Nano, header(sent, HeaderFailedAt))
+			assert.NoError(t, err)
+
+			if tt.wantStage == StageValidate {
+				var violations []validator.Violation
+				require.NoError(t, json.Unmarshal([]byte(header(sent, HeaderViolations)), &violations))
+				assert.NotEmpty(t, violations)
+			} else {
+				assert.Empty(t, header(sent, HeaderViolations))
+			}
+
+			svc.AssertNotCalled(t, "IngestOrder", mock.Anything, mock.Anything, mock.Anything)
+			pub.AssertExpectations(t)
+		})
+	}
+}
+
+func TestConsumer_ConflictIsNotRetried(t *testing.T) {
+	validator.Init()
+
+	svc := &MockService{}
+	c := &Consumer{
+		service: svc,
+		l:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	svc.On("IngestOrder", mock.Anything, mock.Anything, mock.Anything).Return(model.ErrOrderConflict).Once()
+
+	value, err := json.Marshal(validOrder())
+	require.NoError(t, err)
+
+	f := c.processWithRetry(context.Background(), kafka.Message{Key: []byte("034"), Value: value})
+	require.NotNil(t, f)
+	assert.Equal(t, StageConflict, f.stage)
+	assert.ErrorIs(t, f.err, model.ErrOrderConflict)
+	svc.AssertExpectations(t)
+}
+
+func TestConsumer_ProcessBatch(t *testing.T) {
+	validator.Init()
+
+	svc := &MockService{}
+	c := &Consumer{
+		service: svc,
+		l:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+
+	msg := func(uid string, offset int64) kafka.Message {
+		order := validOrder()
+		order.OrderUID = uid
+		order.Payment.Transaction = uid
+		value, err := json.Marshal(order)
+		require.NoError(t, err)
+		return kafka.Message{Topic: "orders", Offset: offset, Key: []byte(uid), Value: value}
+	}
+	msgs := []kafka.Message{
+		msg("1", 1),
+		{Topic: "orders", Offset: 2, Value: []byte(`{"order_uid":`)},
+		msg("3", 3),
+		msg("4", 4),
+	}
+
+	svc.On("IngestOrders", mock.Anything, mock.MatchedBy(func(orders []*model.Order) bool {
+		return len(orders) == 3 && orders[0].OrderUID == "1" && orders[1].OrderUID == "3" && orders[2].OrderUID == "4"
+	}), mock.Anything).Return([]model.SaveResult{
+		{OrderUID: "1", Status: model.SaveInserted},
+		{OrderUID: "3", Status: model.SaveConflict, Err: model.ErrOrderConflict},
+		{OrderUID: "4", Status: model.SaveFailed, Err: errors.New("db error")},
+	}, nil).Once()
+	svc.On("IngestOrder", mock.Anything, mock.MatchedBy(func(order *model.Order) bool {
+		return order.OrderUID == "4"
+	}), messageSource(msgs[3])).Return(nil).Once()
+
+	failures := c.processBatchWithRetry(context.Background(), msgs)
+	require.Len(t, failures, 4)
+	assert.Nil(t, failures[0])
+	require.NotNil(t, failures[1])
+	assert.Equal(t, StageDecode, failures[1].stage)
+	require.NotNil(t, failures[2])
+	assert.Equal(t, StageConflict, failures[2].stage)
+	assert.Nil(t, failures[3])
+	svc.AssertExpectations(t)
+}
+
+func TestMessageSource(t *testing.T) {
+	m := kafka.Message{Topic: "orders", Partition: 3, Offset: 7, Value: []byte(`{"order_uid":"034"}`)}
+
+	src := messageSource(m)
+	assert.Equal(t, "orders", src.Topic)
+	assert.Equal(t, 3, src.Partition)
+	assert.Equal(t, int64(7), src.Offset)
+	assert.Len(t, src.PayloadHash, 64)
+
+	m.Value = []byte(`{"order_uid":"035"}`)
+	assert.NotEqual(t, src.PayloadHash, messageSource(m).PayloadHash)
+}
+
+func TestConsumer_DeadLetterStopsOnCancel(t *testing.T) {
+	pub := &MockPublisher{}
+	c := &Consumer{
+		dlq: pub,
+		l:   slog.New(slog.NewTextHandler(io.Discard, nil)),
+	}
+	pub.On("SendMessage", mock.Anything, mock.Anything, mock.Anything, mock.Anything).
+		Return(errors.New("broker unavailable"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	err := c.deadLetter(ctx, kafka.Message{}, &failure{stage: StagePersist, err: errors.New("db error"), attempts: maxAttempts})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func validOrder() *model.Order {
+	return &model.Order{
+		OrderUID:        "034",
+		TrackNumber:     "WBILMTESTTRACK",
+		Entry:           "WBIL",
+		Locale:          "en",
+		CustomerID:      "test",
+		DeliveryService: "meest",
+		Shardkey:        "9",
+		SmID:            99,
+		DateCreated:     time.Date(2021, 11, 26, 6, 22, 19, 0, time.UTC),
+		OofShard:        "1",
+		Delivery: model.Delivery{
+			Name:    "Test Testov",
+			Phone:   "+9720000000",
+			Zip:     "2639809",
+			City:    "Kiryat Mozkin",
+			Address: "Ploshad Mira 15",
+			Region:  "Kraiot",
+			Email:   "test@gmail.com",
+		},
+		Payment: model.Payment{
+			Transaction:  "034",
+			Currency:     "USD",
+			Provider:     "wbpay",
+			Amount:       1817,
+			PaymentDt:    1637907727,
+			Bank:         "alpha",
+			DeliveryCost: 1500,
+			GoodsTotal:   317,
+		},
+		Items: []model.Item{{
+			ChrtID:      9934930,
+			TrackNumber: "WBILMTESTTRACK",
+			Price:       453,
+			Rid:         "ab4219087a764ae0btest",
+			Name:        "Mascaras",
+			Sale:        30,
+			Size:        "0",
+			TotalPrice:  317,
+			NmID:        2389212,
+			Brand:       "Vivienne Sabo",
+			Status:      202,
+		}},
+	}
+}
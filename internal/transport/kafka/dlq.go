@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	StageDecode     = "decode"
+	StageValidate   = "validate"
+	StagePersist    = "persist"
+	StageTransition = "transition"
+	StageConflict   = "conflict"
+	StageNotFound   = "not_found"
+)
+
+const (
+	HeaderReason          = "dlq-reason"
+	HeaderStage           = "dlq-stage"
+	HeaderAttempts        = "dlq-attempts"
+	HeaderSourceTopic     = "dlq-source-topic"
+	HeaderSourcePartition = "dlq-source-partition"
+	HeaderSourceOffset    = "dlq-source-offset"
+	HeaderFailedAt        = "dlq-failed-at"
+	HeaderViolations      = "dlq-violations"
+)
+
+type DeadLetterPublisher interface {
+	SendMessage(ctx context.Context, key string, value []byte, headers ...kafka.Header) error
+}
+
+type failure struct {
+	stage    string
+	err      error
+	attempts int
+}
+
+func deadLetterHeaders(m kafka.Message, f *failure, failedAt time.Time) []kafka.Header {
+	headers := make([]kafka.Header, 0, len(m.Headers)+7)
+	for _, h := range m.Headers {
+		if !isDeadLetterHeader(h.Key) {
+			headers = append(headers, h)
+		}
+	}
+
+	var verr *validator.Error
+	if errors.As(f.err, &verr) {
+		if violations, err := json.Marshal(verr.Violations); err == nil {
+			headers = append(headers, kafka.Header{Key: HeaderViolations, Value: violations})
+		}
+	}
+
+	return append(headers,
+		kafka.Header{Key: HeaderReason, Value: []byte(f.err.Error())},
+		kafka.Header{Key: HeaderStage, Value: []byte(f.stage)},
+		kafka.Header{Key: HeaderAttempts, Value: []byte(strconv.Itoa(f.attempts))},
+		kafka.Header{Key: HeaderSourceTopic, Value: []byte(m.Topic)},
+		kafka.Header{Key: HeaderSourcePartition, Value: []byte(strconv.Itoa(m.Partition))},
+		kafka.Header{Key: HeaderSourceOffset, Value: []byte(strconv.FormatInt(m.Offset, 10))},
+		kafka.Header{Key: HeaderFailedAt, Value: []byte(failedAt.UTC().Format(time.RFC3339Nano))},
+	)
+}
+
+func isDeadLetterHeader(key string) bool {
+	switch key {
+	case HeaderReason, HeaderStage, HeaderAttempts, HeaderSourceTopic,
+		HeaderSourcePartition, HeaderSourceOffset, HeaderFailedAt, HeaderViolations:
+		return true
+	}
+	return false
+}
+
+func (c *Consumer) deadLetter(ctx context.Context, m kafka.Message, f *failure) error {
+	log := c.l.With(
+		"stage", f.stage,
+		"reason", f.err.Error(),
+		"partition", m.Partition,
+		"offset", m.Offset,
+	)
+
+	if c.dlq == nil {
+		log.Error("dropping message, dead-letter topic is not configured")
+		return nil
+	}
+
+	headers := deadLetterHeaders(m, f, time.Now())
+	currentDelay := baseDelay
+	for {
+		err := c.dlq.SendMessage(ctx, string(m.Key), m.Value, headers...)
+		if err == nil {
+			log.Warn("message sent to dead-letter topic")
+			return nil
+		}
+		log.Error("failed to send message to dead-letter topic, retrying", "error", err)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(currentDelay):
+			currentDelay = min(currentDelay*2, maxDelay)
+		}
+	}
+}
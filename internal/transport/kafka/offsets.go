@@ -0,0 +1,93 @@
+package kafka
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fetchedMessage is a message together with the sequence number the tracker
+// gave it when it was fetched. The same offset fetched again after a rewind
+// gets a new sequence number.
+type fetchedMessage struct {
+	kafka.Message
+	seq uint64
+}
+
+type trackedMessage struct {
+	msg  fetchedMessage
+	done bool
+}
+
+type offsetTracker struct {
+	mu         sync.Mutex
+	seq        uint64
+	partitions map[int][]trackedMessage
+}
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{partitions: make(map[int][]trackedMessage)}
+}
+
+func (t *offsetTracker) add(m kafka.Message) fetchedMessage {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.seq++
+	f := fetchedMessage{Message: m, seq: t.seq}
+
+	queue := t.partitions[m.Partition]
+	if n := len(queue); n > 0 && queue[n-1].msg.Offset >= m.Offset {
+		// The partition was rewound after a rebalance, older in-flight
+		// messages will be delivered again and must not be committed.
+		queue = queue[:0]
+	}
+	t.partitions[m.Partition] = append(queue, trackedMessage{msg: f})
+	return f
+}
+
+// done marks the message as processed. A message that was dropped by a rewind
+// or a rebalance in the meantime is ignored, even if its offset was fetched
+// again.
+func (t *offsetTracker) done(m fetchedMessage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	queue := t.partitions[m.Partition]
+	i := sort.Search(len(queue), func(i int) bool {
+		return queue[i].msg.Offset >= m.Offset
+	})
+	if i < len(queue) && queue[i].msg.seq == m.seq {
+		queue[i].done = true
+	}
+}
+
+// reset forgets every partition. It is called when the group rebalances: the
+// partitions may have been revoked, and those assigned again restart from the
+// committed offset.
+func (t *offsetTracker) reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	clear(t.partitions)
+}
+
+func (t *offsetTracker) committable() []kafka.Message {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var msgs []kafka.Message
+	for p, queue := range t.partitions {
+		n := 0
+		for n < len(queue) && queue[n].done {
+			n++
+		}
+		if n == 0 {
+			continue
+		}
+		msgs = append(msgs, queue[n-1].msg.Message)
+		t.partitions[p] = append(queue[:0], queue[n:]...)
+	}
+	return msgs
+}
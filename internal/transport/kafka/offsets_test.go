@@ -0,0 +1,91 @@
+package kafka
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func offsets(msgs []kafka.Message) map[int]int64 {
+	res := make(map[int]int64, len(msgs))
+	for _, m := range msgs {
+		res[m.Partition] = m.Offset
+	}
+	return res
+}
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker()
+	fetched := make(map[int64]fetchedMessage)
+	for _, m := range []kafka.Message{
+		{Partition: 0, Offset: 10},
+		{Partition: 0, Offset: 11},
+		{Partition: 0, Offset: 12},
+		{Partition: 1, Offset: 5},
+		{Partition: 1, Offset: 6},
+	} {
+		fetched[m.Offset] = tracker.add(m)
+	}
+
+	tracker.done(fetched[11])
+	tracker.done(fetched[5])
+	assert.Equal(t, map[int]int64{1: 5}, offsets(tracker.committable()))
+
+	tracker.done(fetched[10])
+	assert.Equal(t, map[int]int64{0: 11}, offsets(tracker.committable()))
+
+	assert.Empty(t, tracker.committable())
+
+	tracker.done(fetched[12])
+	tracker.done(fetched[6])
+	assert.Equal(t, map[int]int64{0: 12, 1: 6}, offsets(tracker.committable()))
+}
+
+func TestOffsetTracker_Rewind(t *testing.T) {
+	tracker := newOffsetTracker()
+	stale10 := tracker.add(kafka.Message{Partition: 0, Offset: 10})
+	stale11 := tracker.add(kafka.Message{Partition: 0, Offset: 11})
+
+	again10 := tracker.add(kafka.Message{Partition: 0, Offset: 10})
+	again11 := tracker.add(kafka.Message{Partition: 0, Offset: 11})
+
+	// In-flight workers finish the messages fetched before the rewind.
+	tracker.done(stale10)
+	tracker.done(stale11)
+	assert.Empty(t, tracker.committable())
+
+	tracker.done(again10)
+	assert.Equal(t, map[int]int64{0: 10}, offsets(tracker.committable()))
+	tracker.done(again11)
+	assert.Equal(t, map[int]int64{0: 11}, offsets(tracker.committable()))
+}
+
+func TestOffsetTracker_Reset(t *testing.T) {
+	tracker := newOffsetTracker()
+	revoked := tracker.add(kafka.Message{Partition: 0, Offset: 10})
+	kept := tracker.add(kafka.Message{Partition: 1, Offset: 5})
+
+	tracker.reset()
+	tracker.done(revoked)
+	tracker.done(kept)
+	assert.Empty(t, tracker.committable())
+
+	refetched := tracker.add(kafka.Message{Partition: 1, Offset: 5})
+	tracker.done(refetched)
+	assert.Equal(t, map[int]int64{1: 5}, offsets(tracker.committable()))
+}
+
+func TestRoute(t *testing.T) {
+	const workers = 8
+
+	a := kafka.Message{Key: []byte("order-a"), Partition: 0}
+	b := kafka.Message{Key: []byte("order-a"), Partition: 3}
+	assert.Equal(t, route(a, workers), route(b, workers))
+
+	noKey := kafka.Message{Partition: 2}
+	assert.Equal(t, route(noKey, workers), route(noKey, workers))
+	assert.Less(t, route(noKey, workers), workers)
+
+	assert.Equal(t, 0, route(a, 1))
+}
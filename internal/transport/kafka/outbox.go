@@ -0,0 +1,143 @@
+package kafka
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	HeaderEventID   = "event-id"
+	HeaderEventType = "event-type"
+)
+
+const (
+	outboxLease           = 30 * time.Second
+	outboxCleanupInterval = 10 * time.Minute
+)
+
+type OutboxStore interface {
+	ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error)
+	MarkOutboxPublished(ctx context.Context, ids []int64) error
+	MarkOutboxFailed(ctx context.Context, id int64, delay time.Duration, reason string) error
+	DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error)
+}
+
+type EventPublisher interface {
+	SendMessage(ctx context.Context, key string, value []byte, headers ...kafka.Header) error
+}
+
+// OutboxRelay publishes events written to the outbox table. A message is
+// marked as published only after Kafka acknowledged it, so consumers may
+// see duplicates and should deduplicate on the event-id header.
+type OutboxRelay struct {
+	store     OutboxStore
+	pub       EventPublisher
+	interval  time.Duration
+	batchSize int
+	retention time.Duration
+	l         *slog.Logger
+}
+
+func NewOutboxRelay(l *slog.Logger, store OutboxStore, pub EventPublisher, interval time.Duration, batchSize int, retention time.Duration) *OutboxRelay {
+	return &OutboxRelay{
+		store:     store,
+		pub:       pub,
+		interval:  interval,
+		batchSize: max(batchSize, 1),
+		retention: retention,
+		l:         l.With("component", "outbox"),
+	}
+}
+
+func (r *OutboxRelay) Start(ctx context.Context) error {
+	r.l.Info("outbox relay started")
+	defer r.l.Info("outbox relay stopped")
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	lastCleanup := time.Now()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		for {
+			if n := r.relay(ctx); n < r.batchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if time.Since(lastCleanup) >= outboxCleanupInterval {
+			r.cleanup(ctx)
+			lastCleanup = time.Now()
+		}
+	}
+}
+
+// relay publishes one batch and returns the number of claimed messages.
+func (r *OutboxRelay) relay(ctx context.Context) int {
+	msgs, err := r.store.ClaimOutbox(ctx, r.batchSize, outboxLease)
+	if err != nil {
+		if ctx.Err() == nil {
+			r.l.Error("failed to claim outbox messages", "error", err)
+		}
+		return 0
+	}
+
+	published := make([]int64, 0, len(msgs))
+	for _, m := range msgs {
+		headers := []kafka.Header{
+			{Key: HeaderEventID, Value: []byte(strconv.FormatInt(m.ID, 10))},
+			{Key: HeaderEventType, Value: []byte(m.EventType)},
+		}
+		if err := r.pub.SendMessage(ctx, m.Key, m.Payload, headers...); err != nil {
+			delay := retryDelay(m.Attempts)
+			r.l.Warn("failed to publish outbox message, retrying",
+				"id", m.ID, "attempt", m.Attempts, "retry_in", delay, "error", err)
+			if err := r.store.MarkOutboxFailed(ctx, m.ID, delay, err.Error()); err != nil {
+				r.l.Error("failed to release outbox message", "id", m.ID, "error", err)
+			}
+			continue
+		}
+		published = append(published, m.ID)
+	}
+
+	if len(published) > 0 {
+		if err := r.store.MarkOutboxPublished(ctx, published); err != nil {
+			r.l.Error("failed to mark outbox messages as published", "error", err)
+			return 0
+		}
+		r.l.Debug("outbox messages published", "count", len(published))
+	}
+	return len(msgs)
+}
+
+func (r *OutboxRelay) cleanup(ctx context.Context) {
+	if r.retention <= 0 {
+		return
+	}
+	n, err := r.store.DeletePublishedOutbox(ctx, time.Now().Add(-r.retention))
+	if err != nil {
+		r.l.Error("failed to clean up outbox", "error", err)
+		return
+	}
+	if n > 0 {
+		r.l.Info("published outbox messages removed", "count", n)
+	}
+}
+
+func retryDelay(attempts int) time.Duration {
+	delay := baseDelay
+	for i := 1; i < attempts && delay < maxDelay; i++ {
+		delay *= 2
+	}
+	return min(delay, maxDelay)
+}
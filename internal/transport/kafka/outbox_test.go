@@ -0,0 +1,78 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type MockOutboxStore struct {
+	mock.Mock
+}
+
+func (m *MockOutboxStore) ClaimOutbox(ctx context.Context, limit int, lease time.Duration) ([]model.OutboxMessage, error) {
+	args := m.Called(ctx, limit, lease)
+	if args.Get(0) == nil {
+		return nil, args.Error(1)
+	}
+	return args.Get(0).([]model.OutboxMessage), args.Error(1)
+}
+
+func (m *MockOutboxStore) MarkOutboxPublished(ctx context.Context, ids []int64) error {
+	args := m.Called(ctx, ids)
+	return args.Error(0)
+}
+
+func (m *MockOutboxStore) MarkOutboxFailed(ctx context.Context, id int64, delay time.Duration, reason string) error {
+	args := m.Called(ctx, id, delay, reason)
+	return args.Error(0)
+}
+
+func (m *MockOutboxStore) DeletePublishedOutbox(ctx context.Context, before time.Time) (int64, error) {
+	args := m.Called(ctx, before)
+	return args.Get(0).(int64), args.Error(1)
+}
+
+func TestOutboxRelay_Relay(t *testing.T) {
+	store := &MockOutboxStore{}
+	pub := &MockPublisher{}
+	r := NewOutboxRelay(slog.New(slog.NewTextHandler(io.Discard, nil)), store, pub, time.Second, 10, time.Hour)
+
+	msgs := []model.OutboxMessage{
+		{ID: 1, EventType: model.EventOrderCreated, Key: "a", Payload: []byte(`{"order_uid":"a"}`), Attempts: 1},
+		{ID: 2, EventType: model.EventOrderCreated, Key: "b", Payload: []byte(`{"order_uid":"b"}`), Attempts: 3},
+		{ID: 3, EventType: model.EventOrderCreated, Key: "c", Payload: []byte(`{"order_uid":"c"}`), Attempts: 1},
+	}
+	store.On("ClaimOutbox", mock.Anything, 10, outboxLease).Return(msgs, nil)
+
+	var headers []kafka.Header
+	pub.On("SendMessage", mock.Anything, "a", msgs[0].Payload, mock.Anything).
+		Run(func(args mock.Arguments) { headers = args.Get(3).([]kafka.Header) }).
+		Return(nil)
+	pub.On("SendMessage", mock.Anything, "b", msgs[1].Payload, mock.Anything).Return(errors.New("broker unavailable"))
+	pub.On("SendMessage", mock.Anything, "c", msgs[2].Payload, mock.Anything).Return(nil)
+
+	store.On("MarkOutboxFailed", mock.Anything, int64(2), 4*time.Second, mock.Anything).Return(nil)
+	store.On("MarkOutboxPublished", mock.Anything, []int64{1, 3}).Return(nil)
+
+	assert.Equal(t, 3, r.relay(context.Background()))
+	assert.Equal(t, "1", header(headers, HeaderEventID))
+	assert.Equal(t, model.EventOrderCreated, header(headers, HeaderEventType))
+
+	store.AssertExpectations(t)
+	pub.AssertExpectations(t)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, baseDelay, retryDelay(1))
+	assert.Equal(t, 2*baseDelay, retryDelay(2))
+	assert.Equal(t, maxDelay, retryDelay(10))
+}
@@ -17,7 +17,9 @@ func NewProducer(ctx context.Context, brokers []string, topic string) (*Producer
 	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
 	defer cancel()
 
-	conn, err := kafka.DialContext(ctx, "tcp", brokers[0])
+	conn, err := dialAny(brokers, func(broker string) (*kafka.Conn, error) {
+		return kafka.DialContext(ctx, "tcp", broker)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("%s: %w", op, err)
 	}
@@ -37,12 +39,13 @@ func NewProducer(ctx context.Context, brokers []string, topic string) (*Producer
 	return &Producer{writer: w}, nil
 }
 
-func (p *Producer) SendMessage(ctx context.Context, key string, value []byte) error {
+func (p *Producer) SendMessage(ctx context.Context, key string, value []byte, headers ...kafka.Header) error {
 	const op = "kafka.Producer.SendMessage"
 
 	msg := kafka.Message{
-		Key:   []byte(key),
-		Value: value,
+		Key:     []byte(key),
+		Value:   value,
+		Headers: headers,
 	}
 
 	if err := p.writer.WriteMessages(ctx, msg); err != nil {
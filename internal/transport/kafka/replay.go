@@ -0,0 +1,176 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type DeadLetter struct {
+	Message         kafka.Message
+	Reason          string
+	Stage           string
+	Attempts        int
+	FailedAt        time.Time
+	SourceTopic     string
+	SourcePartition int
+	SourceOffset    int64
+}
+
+func ParseDeadLetter(m kafka.Message) DeadLetter {
+	dl := DeadLetter{
+		Message:         m,
+		SourcePartition: -1,
+		SourceOffset:    -1,
+	}
+	for _, h := range m.Headers {
+		v := string(h.Value)
+		switch h.Key {
+		case HeaderReason:
+			dl.Reason = v
+		case HeaderStage:
+			dl.Stage = v
+		case HeaderAttempts:
+			dl.Attempts, _ = strconv.Atoi(v)
+		case HeaderSourceTopic:
+			dl.SourceTopic = v
+		case HeaderSourcePartition:
+			if p, err := strconv.Atoi(v); err == nil {
+				dl.SourcePartition = p
+			}
+		case HeaderSourceOffset:
+			if o, err := strconv.ParseInt(v, 10, 64); err == nil {
+				dl.SourceOffset = o
+			}
+		case HeaderFailedAt:
+			dl.FailedAt, _ = time.Parse(time.RFC3339Nano, v)
+		}
+	}
+	return dl
+}
+
+func (dl DeadLetter) OriginalHeaders() []kafka.Header {
+	headers := make([]kafka.Header, 0, len(dl.Message.Headers))
+	for _, h := range dl.Message.Headers {
+		if !isDeadLetterHeader(h.Key) {
+			headers = append(headers, h)
+		}
+	}
+	return headers
+}
+
+type Range struct {
+	Partition  int
+	FromOffset int64
+	ToOffset   int64
+	Since      time.Time
+	Until      time.Time
+}
+
+func ReadTopic(ctx context.Context, brokers []string, topic string, rng Range, fn func(kafka.Message) error) error {
+	const op = "kafka.ReadTopic"
+
+	conn, err := dialAny(brokers, func(broker string) (*kafka.Conn, error) {
+		return kafka.DialContext(ctx, "tcp", broker)
+	})
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+	partitions, err := conn.ReadPartitions(topic)
+	_ = conn.Close()
+	if err != nil {
+		return fmt.Errorf("%s: %w", op, err)
+	}
+
+	for _, p := range partitions {
+		if rng.Partition >= 0 && p.ID != rng.Partition {
+			continue
+		}
+		if err := readPartition(ctx, brokers, topic, p.ID, rng, fn); err != nil {
+			return fmt.Errorf("%s: partition %d: %w", op, p.ID, err)
+		}
+	}
+	return nil
+}
+
+// dialAny tries the brokers in order until one of them answers, the same way
+// the reader and the writer fall back across the configured brokers.
+func dialAny(brokers []string, dial func(broker string) (*kafka.Conn, error)) (*kafka.Conn, error) {
+	errs := make([]error, 0, len(brokers))
+	for _, broker := range brokers {
+		conn, err := dial(broker)
+		if err == nil {
+			return conn, nil
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", broker, err))
+	}
+	if len(errs) == 0 {
+		return nil, errors.New("no brokers configured")
+	}
+	return nil, errors.Join(errs...)
+}
+
+func readPartition(ctx context.Context, brokers []string, topic string, partition int, rng Range, fn func(kafka.Message) error) error {
+	leader, err := dialAny(brokers, func(broker string) (*kafka.Conn, error) {
+		return kafka.DialLeader(ctx, "tcp", broker, topic, partition)
+	})
+	if err != nil {
+		return err
+	}
+	first, last, err := leader.ReadOffsets()
+	if err == nil && !rng.Since.IsZero() {
+		var at int64
+		if at, err = leader.ReadOffset(rng.Since); err == nil {
+			first = max(first, at)
+		}
+	}
+	_ = leader.Close()
+	if err != nil {
+		return err
+	}
+
+	start := max(first, rng.FromOffset)
+	end := last
+	if rng.ToOffset >= 0 {
+		end = min(end, rng.ToOffset+1)
+	}
+	if start >= end {
+		return nil
+	}
+
+	r := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     topic,
+		Partition: partition,
+		MinBytes:  1,
+		MaxBytes:  10e6,
+		MaxWait:   1 * time.Second,
+	})
+	defer func() {
+		_ = r.Close()
+	}()
+
+	if err := r.SetOffset(start); err != nil {
+		return err
+	}
+
+	for {
+		m, err := r.ReadMessage(ctx)
+		if err != nil {
+			return err
+		}
+		if !rng.Until.IsZero() && m.Time.After(rng.Until) {
+			return nil
+		}
+		if err := fn(m); err != nil {
+			return err
+		}
+		if m.Offset+1 >= end {
+			return nil
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package kafka
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDeadLetter(t *testing.T) {
+	failedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	source := kafka.Message{
+		Topic:     "orders",
+		Partition: 1,
+		Offset:    17,
+		Key:       []byte("034"),
+		Value:     []byte(`{}`),
+		Headers:   []kafka.Header{{Key: "traceparent", Value: []byte("00-abc")}},
+	}
+	f := &failure{stage: StagePersist, err: errors.New("db error"), attempts: 15}
+
+	dl := ParseDeadLetter(kafka.Message{
+		Key:     source.Key,
+		Value:   source.Value,
+		Headers: deadLetterHeaders(source, f, failedAt),
+	})
+
+	assert.Equal(t, "db error", dl.Reason)
+	assert.Equal(t, StagePersist, dl.Stage)
+	assert.Equal(t, 15, dl.Attempts)
+	assert.Equal(t, "orders", dl.SourceTopic)
+	assert.Equal(t, 1, dl.SourcePartition)
+	assert.Equal(t, int64(17), dl.SourceOffset)
+	assert.True(t, failedAt.Equal(dl.FailedAt))
+	assert.Equal(t, source.Headers, dl.OriginalHeaders())
+}
+
+func TestDialAny(t *testing.T) {
+	want := &kafka.Conn{}
+	var tried []string
+	conn, err := dialAny([]string{"a:9092", "b:9092", "c:9092"}, func(broker string) (*kafka.Conn, error) {
+		tried = append(tried, broker)
+		if broker == "b:9092" {
+			return want, nil
+		}
+		return nil, errors.New("connection refused")
+	})
+	assert.NoError(t, err)
+	assert.Same(t, want, conn)
+	assert.Equal(t, []string{"a:9092", "b:9092"}, tried)
+
+	_, err = dialAny([]string{"a:9092", "b:9092"}, func(string) (*kafka.Conn, error) {
+		return nil, errors.New("connection refused")
+	})
+	assert.ErrorContains(t, err, "a:9092")
+	assert.ErrorContains(t, err, "b:9092")
+
+	_, err = dialAny(nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/segmentio/kafka-go"
+)
+
+type StatusService interface {
+	ApplyStatusEvent(ctx context.Context, event *model.StatusEvent) error
+}
+
+// NewStatusConsumer reads order status events emitted by warehouse and
+// delivery systems. Events for an unknown order, with an unknown status or an
+// illegal or conflicting transition are sent to the dead-letter topic without
+// retries.
+func NewStatusConsumer(l *slog.Logger, brokers []string, group, topic string, workers int, service StatusService, dlq DeadLetterPublisher) *Consumer {
+	c := newConsumer(l, brokers, group, topic, workers, dlq)
+	c.statuses = service
+	c.process = c.processStatusEvent
+	return c
+}
+
+func (c *Consumer) processStatusEvent(ctx context.Context, m kafka.Message) *failure {
+	var event model.StatusEvent
+	if err := json.Unmarshal(m.Value, &event); err != nil {
+		c.l.Error("invalid json", "error", err, "offset", m.Offset)
+		return &failure{stage: StageDecode, err: err, attempts: 1}
+	}
+
+	if err := validator.ValidateStatusEvent(&event); err != nil {
+		c.logInvalid("invalid status event", event.OrderUID, err)
+		return &failure{stage: StageValidate, err: err, attempts: 1}
+	}
+
+	var rejected error
+	f := c.retry(ctx, func(ctx context.Context) error {
+		err := c.statuses.ApplyStatusEvent(ctx, &event)
+		if permanentStatusError(err) {
+			rejected = err
+			return nil
+		}
+		return err
+	})
+	if rejected != nil {
+		c.l.Warn("status event rejected", "event_id", event.EventID, "error", rejected)
+		stage := StageTransition
+		if errors.Is(rejected, model.ErrNotFound) {
+			stage = StageNotFound
+		}
+		return &failure{stage: stage, err: rejected, attempts: 1}
+	}
+	return f
+}
+
+// permanentStatusError reports whether applying the event again cannot
+// succeed. A conflicting transition means the order moved on since the event
+// was read, so the event is stale.
+func permanentStatusError(err error) bool {
+	return errors.Is(err, model.ErrNotFound) ||
+		errors.Is(err, model.ErrInvalidStatus) ||
+		errors.Is(err, model.ErrInvalidTransition) ||
+		errors.Is(err, model.ErrStatusConflict)
+}
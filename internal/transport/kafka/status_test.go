@@ -0,0 +1,97 @@
+package kafka
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/MikebangSfilya/wb/internal/lib/validator"
+	"github.com/MikebangSfilya/wb/internal/model"
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type MockStatusService struct {
+	mock.Mock
+}
+
+func (m *MockStatusService) ApplyStatusEvent(ctx context.Context, event *model.StatusEvent) error {
+	args := m.Called(ctx, event)
+	return args.Error(0)
+}
+
+func TestConsumer_ProcessStatusEvent(t *testing.T) {
+	validator.Init()
+
+	tests := []struct {
+		name      string
+		value     []byte
+		applyErr  error
+		wantStage string
+	}{
+		{
+			name:  "applied",
+			value: []byte(`{"event_id":"evt-1","order_uid":"034","status":"paid"}`),
+		},
+		{
+			name:      "invalid_json",
+			value:     []byte(`{"event_id":`),
+			wantStage: StageDecode,
+		},
+		{
+			name:      "missing_event_id",
+			value:     []byte(`{"order_uid":"034","status":"paid"}`),
+			wantStage: StageValidate,
+		},
+		{
+			name:      "illegal_transition",
+			value:     []byte(`{"event_id":"evt-1","order_uid":"034","status":"delivered"}`),
+			applyErr:  model.ErrInvalidTransition,
+			wantStage: StageTransition,
+		},
+		{
+			name:      "unknown_status",
+			value:     []byte(`{"event_id":"evt-1","order_uid":"034","status":"lost"}`),
+			applyErr:  model.ErrInvalidStatus,
+			wantStage: StageTransition,
+		},
+		{
+			name:      "status_conflict",
+			value:     []byte(`{"event_id":"evt-1","order_uid":"034","status":"shipped"}`),
+			applyErr:  model.ErrStatusConflict,
+			wantStage: StageTransition,
+		},
+		{
+			name:      "unknown_order",
+			value:     []byte(`{"event_id":"evt-1","order_uid":"034","status":"paid"}`),
+			applyErr:  model.ErrNotFound,
+			wantStage: StageNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svc := &MockStatusService{}
+			c := &Consumer{
+				statuses: svc,
+				l:        slog.New(slog.NewTextHandler(io.Discard, nil)),
+			}
+			if tt.wantStage != StageDecode && tt.wantStage != StageValidate {
+				svc.On("ApplyStatusEvent", mock.Anything, mock.Anything).Return(tt.applyErr).Once()
+			}
+
+			f := c.processStatusEvent(context.Background(), kafka.Message{Key: []byte("034"), Value: tt.value})
+			if tt.wantStage == "" {
+				assert.Nil(t, f)
+			} else {
+				require.NotNil(t, f)
+				assert.Equal(t, tt.wantStage, f.stage)
+				assert.Equal(t, 1, f.attempts)
+			}
+			svc.AssertExpectations(t)
+		})
+	}
+}